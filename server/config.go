@@ -0,0 +1,494 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ace_away/game"
+)
+
+// Prefix for the environment variables which override the config file.
+const envPrefix = "ACE_"
+
+// duration which can be written as a string (`"5m"`, `"30s"`) in the config.
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return d.set(s)
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = v
+	return nil
+}
+
+// config for the server. Values are taken from the defaults, the config file,
+// the environment (`ACE_*`) and the command line (in increasing order of precedence).
+type config struct {
+	// Address on which the server listens (`host:port`).
+	Listen string `json:"listen"`
+	// Certificate and key (PEM) for serving over TLS. The certificate is
+	// reloaded whenever these files change.
+	TLSCert string `json:"tlsCert"`
+	TLSKey  string `json:"tlsKey"`
+	// Address on which plain HTTP requests are redirected to HTTPS (if any).
+	HTTPRedirect string `json:"httpRedirect"`
+	// Path prefix under which everything is served (e.g., `/ace-away`).
+	BasePath string `json:"basePath"`
+	// Whether to trust `X-Forwarded-For` and `X-Forwarded-Proto` headers
+	// (only enable this behind a reverse proxy).
+	TrustProxy bool `json:"trustProxy"`
+	// Origins allowed to use the websocket and HTTP endpoints from browsers
+	// (only the server's own origin is allowed if this is empty).
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// Limits for events (by name, or `*` for the rest) from each connection
+	// and from each client address. Defaults are used if these are missing.
+	ConnRateLimits map[string]game.RateLimit `json:"connRateLimits"`
+	AddrRateLimits map[string]game.RateLimit `json:"addrRateLimits"`
+	// Number of limited events after which a connection is dropped.
+	MaxRateViolations int `json:"maxRateViolations"`
+	// Max size of messages from clients (in bytes).
+	MaxMessageBytes int `json:"maxMessageBytes"`
+	// Limits for rooms in the server, rooms created from each client address
+	// and connections from each client address.
+	MaxRooms        int `json:"maxRooms"`
+	MaxRoomsPerAddr int `json:"maxRoomsPerAddr"`
+	MaxConnsPerAddr int `json:"maxConnsPerAddr"`
+	// Number of players who can wait for creating rooms when the server is full.
+	RoomQueue int `json:"roomQueue"`
+	// Directory with the static files.
+	Path string `json:"path"`
+	// Limits for the number of players in a room.
+	MinPlayers uint8 `json:"minPlayers"`
+	MaxPlayers uint8 `json:"maxPlayers"`
+	// How long rooms are kept around after everyone has left.
+	RoomTimeout duration `json:"roomTimeout"`
+	// Interval for cleaning up rooms and sessions.
+	CleanupInterval duration `json:"cleanupInterval"`
+	// How long HTTP sessions live without the client polling or streaming.
+	SessionTimeout duration `json:"sessionTimeout"`
+	// File to which the logs are appended (logs go to stderr if this is empty).
+	LogFile string `json:"logFile"`
+	// Prefix for all log lines.
+	LogPrefix string `json:"logPrefix"`
+	// Features, encodings and transports which should be disabled.
+	Disable []string `json:"disable"`
+}
+
+// Settings which can be set through the environment or the command line.
+var configKeys = []struct {
+	name, usage string
+}{
+	{"listen", "Listening address (host:port)"},
+	{"port", "Listening port (shorthand for -listen :port)"},
+	{"tls-cert", "Certificate file for serving over TLS"},
+	{"tls-key", "Key file for serving over TLS"},
+	{"http-redirect", "Listening address for redirecting HTTP to HTTPS (host:port)"},
+	{"base-path", "Path prefix under which everything is served"},
+	{"trust-proxy", "Trust X-Forwarded-For and X-Forwarded-Proto headers"},
+	{"allowed-origins", "Comma-separated origins allowed to connect from browsers (* for any)"},
+	{"conn-rate-limits", "Limits for events from each connection (like PlayerMsg=1:5,*=10:20 for rate:burst)"},
+	{"addr-rate-limits", "Limits for events from each client address (like PlayerMsg=3:15,*=30:60)"},
+	{"max-rate-violations", "Number of limited events after which a connection is dropped"},
+	{"max-message-bytes", "Max size of messages from clients (in bytes)"},
+	{"max-rooms", "Max rooms in the server"},
+	{"max-rooms-per-addr", "Max rooms created from each client address"},
+	{"max-conns-per-addr", "Max connections from each client address"},
+	{"room-queue", "Number of players who can wait for rooms when the server is full (0 for no queue)"},
+	{"path", "Path to serve directory (required)"},
+	{"min-players", "Min players allowed in a room"},
+	{"max-players", "Max players allowed in a room"},
+	{"room-timeout", "Timeout for removing rooms after everyone has left"},
+	{"cleanup-interval", "Interval for cleaning up rooms and sessions"},
+	{"session-timeout", "Timeout for idle HTTP sessions"},
+	{"log-file", "File to which the logs are appended"},
+	{"log-prefix", "Prefix for all log lines"},
+	{"disable", "Comma-separated features to disable (" + strings.Join(game.Toggles(), ", ") + ")"},
+}
+
+// defaultConfig returns the settings used when nothing has been configured.
+// Rate limits are left empty, since the hub uses its defaults for those.
+func defaultConfig() *config {
+	opts := game.DefaultOptions()
+	return &config{
+		Listen:            ":3000",
+		MinPlayers:        opts.MinPlayers,
+		MaxPlayers:        opts.MaxPlayers,
+		MaxRateViolations: opts.MaxRateViolations,
+		MaxMessageBytes:   opts.MaxMessageBytes,
+		MaxRooms:          opts.MaxRooms,
+		MaxRoomsPerAddr:   opts.MaxRoomsPerAddr,
+		MaxConnsPerAddr:   opts.MaxConnsPerAddr,
+		RoomQueue:         opts.RoomQueue,
+		RoomTimeout:       duration{opts.RoomTimeout},
+		CleanupInterval:   duration{opts.CleanupInterval},
+		SessionTimeout:    duration{opts.SessionTimeout},
+	}
+}
+
+// loadConfig from the defaults, the given file, the environment and the
+// flags which have been set explicitly (in increasing order of precedence).
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+	err := cfg.load(path)
+	if err == nil {
+		err = cfg.loadEnv(os.LookupEnv)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if err == nil && f.Name != "config" {
+			if e := cfg.set(f.Name, f.Value.String()); e != nil {
+				err = fmt.Errorf("invalid value for -%s: %v", f.Name, e)
+			}
+		}
+	})
+
+	if err == nil {
+		err = cfg.validate()
+	}
+
+	return cfg, err
+}
+
+// load the config file (if any) over the current settings.
+func (c *config) load(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overrides the settings with the ones found in the environment.
+func (c *config) loadEnv(lookup func(string) (string, bool)) error {
+	for _, k := range configKeys {
+		if v, ok := lookup(envName(k.name)); ok {
+			if err := c.set(k.name, v); err != nil {
+				return fmt.Errorf("invalid value for %s: %v", envName(k.name), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// registerFlags for all the settings in the given flag set, with the given
+// defaults shown in the usage. Flags are typed as per their settings, so
+// that bad values are rejected while parsing.
+func registerFlags(fs *flag.FlagSet, defaults *config) {
+	for _, k := range configKeys {
+		switch v := defaults.value(k.name).(type) {
+		case bool:
+			fs.Bool(k.name, v, k.usage)
+		case uint:
+			fs.Uint(k.name, v, k.usage)
+		case time.Duration:
+			fs.Duration(k.name, v, k.usage)
+		default:
+			fs.String(k.name, v.(string), k.usage)
+		}
+	}
+}
+
+// value of the given setting as a string, bool, uint or duration
+// (for the flags).
+func (c *config) value(key string) interface{} {
+	switch key {
+	case "listen":
+		return c.Listen
+	case "port":
+		return uint(0)
+	case "tls-cert":
+		return c.TLSCert
+	case "tls-key":
+		return c.TLSKey
+	case "http-redirect":
+		return c.HTTPRedirect
+	case "base-path":
+		return c.BasePath
+	case "trust-proxy":
+		return c.TrustProxy
+	case "allowed-origins":
+		return strings.Join(c.AllowedOrigins, ",")
+	case "conn-rate-limits":
+		return formatRateLimits(c.ConnRateLimits)
+	case "addr-rate-limits":
+		return formatRateLimits(c.AddrRateLimits)
+	case "max-rate-violations":
+		return uint(c.MaxRateViolations)
+	case "max-message-bytes":
+		return uint(c.MaxMessageBytes)
+	case "max-rooms":
+		return uint(c.MaxRooms)
+	case "max-rooms-per-addr":
+		return uint(c.MaxRoomsPerAddr)
+	case "max-conns-per-addr":
+		return uint(c.MaxConnsPerAddr)
+	case "room-queue":
+		return uint(c.RoomQueue)
+	case "path":
+		return c.Path
+	case "min-players":
+		return uint(c.MinPlayers)
+	case "max-players":
+		return uint(c.MaxPlayers)
+	case "room-timeout":
+		return c.RoomTimeout.Duration
+	case "cleanup-interval":
+		return c.CleanupInterval.Duration
+	case "session-timeout":
+		return c.SessionTimeout.Duration
+	case "log-file":
+		return c.LogFile
+	case "log-prefix":
+		return c.LogPrefix
+	case "disable":
+		return strings.Join(c.Disable, ",")
+	}
+
+	return ""
+}
+
+// envName returns the environment variable for the given setting.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+// set the value for the given key (from the environment or the command line).
+func (c *config) set(key, value string) error {
+	switch key {
+	case "listen":
+		c.Listen = value
+	case "port":
+		if _, err := strconv.ParseUint(value, 10, 16); err != nil {
+			return err
+		}
+
+		c.Listen = ":" + value
+	case "tls-cert":
+		c.TLSCert = value
+	case "tls-key":
+		c.TLSKey = value
+	case "http-redirect":
+		c.HTTPRedirect = value
+	case "base-path":
+		c.BasePath = value
+	case "trust-proxy":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		c.TrustProxy = v
+	case "path":
+		c.Path = value
+	case "min-players", "max-players":
+		n, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return err
+		}
+
+		if key == "min-players" {
+			c.MinPlayers = uint8(n)
+		} else {
+			c.MaxPlayers = uint8(n)
+		}
+	case "room-timeout":
+		return c.RoomTimeout.set(value)
+	case "cleanup-interval":
+		return c.CleanupInterval.set(value)
+	case "session-timeout":
+		return c.SessionTimeout.set(value)
+	case "log-file":
+		c.LogFile = value
+	case "log-prefix":
+		c.LogPrefix = value
+	case "conn-rate-limits", "addr-rate-limits":
+		limits, err := parseRateLimits(value)
+		if err != nil {
+			return err
+		}
+
+		if key == "conn-rate-limits" {
+			c.ConnRateLimits = limits
+		} else {
+			c.AddrRateLimits = limits
+		}
+	case "max-rate-violations", "max-message-bytes", "max-rooms", "max-rooms-per-addr", "max-conns-per-addr", "room-queue":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "max-rate-violations":
+			c.MaxRateViolations = n
+		case "max-message-bytes":
+			c.MaxMessageBytes = n
+		case "max-rooms":
+			c.MaxRooms = n
+		case "max-rooms-per-addr":
+			c.MaxRoomsPerAddr = n
+		case "max-conns-per-addr":
+			c.MaxConnsPerAddr = n
+		default:
+			c.RoomQueue = n
+		}
+	case "allowed-origins":
+		c.AllowedOrigins = splitList(value)
+	case "disable":
+		c.Disable = splitList(value)
+	default:
+		return fmt.Errorf("unknown setting %s", key)
+	}
+
+	return nil
+}
+
+// splitList of comma-separated values.
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// parseRateLimits written as `event=rate:burst` (separated by commas).
+func parseRateLimits(value string) (map[string]game.RateLimit, error) {
+	limits := make(map[string]game.RateLimit)
+	for _, item := range splitList(value) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected event=rate:burst instead of %q", item)
+		}
+
+		var l game.RateLimit
+		if _, err := fmt.Sscanf(parts[1], "%g:%g", &l.Rate, &l.Burst); err != nil {
+			return nil, fmt.Errorf("expected event=rate:burst instead of %q", item)
+		}
+
+		limits[strings.TrimSpace(parts[0])] = l
+	}
+
+	return limits, nil
+}
+
+// formatRateLimits as `event=rate:burst` (separated by commas).
+func formatRateLimits(limits map[string]game.RateLimit) string {
+	items := make([]string, 0, len(limits))
+	for event, l := range limits {
+		items = append(items, fmt.Sprintf("%s=%g:%g", event, l.Rate, l.Burst))
+	}
+
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// validate the settings before starting the server.
+func (c *config) validate() error {
+	if c.Path == "" {
+		return errors.New("path to serve directory is required")
+	}
+
+	if c.Listen == "" {
+		return errors.New("listening address is required")
+	}
+
+	if c.BasePath != "" && !strings.HasPrefix(c.BasePath, "/") {
+		return errors.New("base path should begin with a slash")
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("both certificate and key are required for TLS")
+	}
+
+	if c.HTTPRedirect != "" && c.TLSCert == "" {
+		return errors.New("redirecting to HTTPS requires TLS")
+	}
+
+	if c.RoomTimeout.Duration <= 0 || c.CleanupInterval.Duration <= 0 || c.SessionTimeout.Duration <= 0 {
+		return errors.New("timeouts and intervals should be positive")
+	}
+
+	opts := c.options(nil)
+	return opts.Validate()
+}
+
+// logger for the server as per the settings.
+func (c *config) logger() (*log.Logger, error) {
+	var w io.Writer = os.Stderr
+	if c.LogFile != "" {
+		f, err := os.OpenFile(c.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		w = f
+	}
+
+	return log.New(w, c.LogPrefix, log.LstdFlags), nil
+}
+
+// options for the hub as per the settings.
+func (c *config) options(logger *log.Logger) game.Options {
+	return game.Options{
+		Logger:            logger,
+		MinPlayers:        c.MinPlayers,
+		MaxPlayers:        c.MaxPlayers,
+		RoomTimeout:       c.RoomTimeout.Duration,
+		CleanupInterval:   c.CleanupInterval.Duration,
+		SessionTimeout:    c.SessionTimeout.Duration,
+		Disable:           c.Disable,
+		TrustProxy:        c.TrustProxy,
+		AllowedOrigins:    c.AllowedOrigins,
+		ConnRateLimits:    c.ConnRateLimits,
+		AddrRateLimits:    c.AddrRateLimits,
+		MaxRateViolations: c.MaxRateViolations,
+		MaxMessageBytes:   c.MaxMessageBytes,
+		MaxRooms:          c.MaxRooms,
+		MaxRoomsPerAddr:   c.MaxRoomsPerAddr,
+		MaxConnsPerAddr:   c.MaxConnsPerAddr,
+		RoomQueue:         c.RoomQueue,
+	}
+}
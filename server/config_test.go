@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"ace_away/game"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "ace-config")
+	assert.Nil(err)
+	defer os.Remove(f.Name())
+	f.WriteString(`{"path": "/srv/ace", "maxPlayers": 8, "roomTimeout": "10m", "disable": ["msgpack"]}`)
+	f.Close()
+
+	cfg := defaultConfig()
+	assert.Nil(cfg.load(f.Name()))
+	assert.Equal("/srv/ace", cfg.Path)
+	assert.EqualValues(8, cfg.MaxPlayers)
+	assert.Equal(10*time.Minute, cfg.RoomTimeout.Duration)
+	assert.Equal(30*time.Second, cfg.CleanupInterval.Duration)
+
+	// Environment overrides the file.
+	env := map[string]string{
+		"ACE_PORT":            "8080",
+		"ACE_MIN_PLAYERS":     "2",
+		"ACE_DISABLE":         "api, http",
+		"ACE_ALLOWED_ORIGINS": "https://a.example,https://b.example",
+	}
+	assert.Nil(cfg.loadEnv(func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}))
+	assert.Equal(":8080", cfg.Listen)
+	assert.EqualValues(2, cfg.MinPlayers)
+	assert.Equal([]string{"api", "http"}, cfg.Disable)
+	assert.Equal([]string{"https://a.example", "https://b.example"}, cfg.AllowedOrigins)
+	assert.Nil(cfg.validate())
+
+	assert.Nil(cfg.set("conn-rate-limits", "PlayerMsg=0.5:3, *=10:20"))
+	assert.Equal(game.RateLimit{Rate: 0.5, Burst: 3}, cfg.ConnRateLimits["PlayerMsg"])
+	assert.Equal(game.RateLimit{Rate: 10, Burst: 20}, cfg.ConnRateLimits["*"])
+	assert.NotNil(cfg.set("addr-rate-limits", "PlayerMsg=fast"))
+	assert.Nil(cfg.validate())
+	cfg.ConnRateLimits["*"] = game.RateLimit{Rate: 10}
+	assert.NotNil(cfg.validate())
+	cfg.ConnRateLimits = nil
+
+	assert.Nil(cfg.set("max-rooms", "50"))
+	assert.Nil(cfg.set("room-queue", "5"))
+	assert.Equal(50, cfg.MaxRooms)
+	assert.Equal(5, cfg.RoomQueue)
+
+	assert.NotNil(cfg.set("room-timeout", "soon"))
+	assert.NotNil(cfg.set("port", "-1"))
+
+	cfg.MaxPlayers = 1
+	assert.NotNil(cfg.validate())
+
+	cfg = defaultConfig()
+	assert.NotNil(cfg.validate()) // path is required
+	cfg.Path = "."
+	cfg.HTTPRedirect = ":80"
+	assert.NotNil(cfg.validate()) // redirect needs TLS
+	cfg.TLSCert, cfg.TLSKey = "cert.pem", "key.pem"
+	assert.Nil(cfg.validate())
+	cfg.TLSKey = ""
+	assert.NotNil(cfg.validate())
+	cfg.TLSKey = "key.pem"
+	cfg.Disable = []string{"teleportation"}
+	assert.NotNil(cfg.validate())
+}
+
+func TestConfigFlags(t *testing.T) {
+	assert := assert.New(t)
+	flags := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("ace", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		registerFlags(fs, defaultConfig())
+		return fs
+	}
+
+	// Defaults come from the hub.
+	fs := flags()
+	opts := game.DefaultOptions()
+	assert.Equal(":3000", fs.Lookup("listen").DefValue)
+	assert.Equal(strconv.Itoa(int(opts.MaxPlayers)), fs.Lookup("max-players").DefValue)
+	assert.Equal(strconv.Itoa(opts.MaxMessageBytes), fs.Lookup("max-message-bytes").DefValue)
+	assert.Equal(opts.RoomTimeout.String(), fs.Lookup("room-timeout").DefValue)
+	assert.Equal("false", fs.Lookup("trust-proxy").DefValue)
+
+	assert.Nil(fs.Parse([]string{"-trust-proxy", "-port", "8080", "-room-timeout", "2m"}))
+	cfg := defaultConfig()
+	fs.Visit(func(f *flag.Flag) {
+		assert.Nil(cfg.set(f.Name, f.Value.String()))
+	})
+	assert.True(cfg.TrustProxy)
+	assert.Equal(":8080", cfg.Listen)
+	assert.Equal(2*time.Minute, cfg.RoomTimeout.Duration)
+
+	// Bad values are rejected right away.
+	assert.NotNil(flags().Parse([]string{"-port", "http"}))
+	assert.NotNil(flags().Parse([]string{"-max-rooms", "-1"}))
+	assert.NotNil(flags().Parse([]string{"-session-timeout", "soon"}))
+}
+
+func TestConfigChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, next := defaultConfig(), defaultConfig()
+	assert.Empty(cfg.changes(next))
+
+	next.Listen = ":4000"
+	next.RoomTimeout.Duration = time.Minute
+	changes := next.changes(cfg)
+	assert.Len(changes, 2)
+	assert.Equal("listen", changes[0].name)
+	assert.Equal(":4000", changes[0].old)
+	assert.Equal(":3000", changes[0].new)
+	assert.Equal("roomTimeout", changes[1].name)
+
+	next.keepRestartSettings(cfg)
+	assert.Equal(":3000", next.Listen)
+	assert.Equal(time.Minute, next.RoomTimeout.Duration)
+}
@@ -0,0 +1,50 @@
+package game
+
+// Max number of recent submissions remembered for each player.
+const maxRememberedActions = 16
+
+// playerAction is the outcome of a player's submission.
+type playerAction struct {
+	// Client-generated ID of the submission.
+	id string
+	// Error returned for the submission (if any).
+	err *HandlerError
+	// Range of sequence numbers for messages sent to the room
+	// as a result of the submission (exclusive of `fromSeq`).
+	fromSeq uint64
+	toSeq   uint64
+}
+
+// findAction returns the outcome of an earlier submission with the given ID (if any).
+func (p *Player) findAction(id string) *playerAction {
+	if id == "" {
+		return nil
+	}
+
+	for i := range p.actions {
+		if p.actions[i].id == id {
+			return &p.actions[i]
+		}
+	}
+
+	return nil
+}
+
+// rememberAction records the outcome of the player's submission. Old
+// submissions are forgotten once we've reached the limit.
+func (p *Player) rememberAction(id string, err *HandlerError, fromSeq, toSeq uint64) {
+	if id == "" {
+		return
+	}
+
+	p.actions = append(p.actions, playerAction{
+		id:      id,
+		err:     err,
+		fromSeq: fromSeq,
+		toSeq:   toSeq,
+	})
+
+	if len(p.actions) > maxRememberedActions {
+		p.actions = p.actions[len(p.actions)-maxRememberedActions:]
+	}
+}
@@ -0,0 +1,411 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// seatConnection is the connection for players using the REST API. Messages
+// aren't pushed to them, they read the view (or the room's events) instead.
+type seatConnection struct {
+	// Token which authenticates requests for this seat.
+	token string
+	lock  sync.Mutex
+	// Last time the seat was used.
+	lastSeen time.Time
+}
+
+func (c *seatConnection) send(data []byte) error {
+	return nil
+}
+
+// touch the seat when it's been used.
+func (c *seatConnection) touch() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastSeen = time.Now()
+}
+
+// idle returns whether the seat hasn't been used for the given duration.
+func (c *seatConnection) idle(timeout time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return time.Since(c.lastSeen) > timeout
+}
+
+// APIJoinRequest from the client for creating (or joining) a room.
+type APIJoinRequest struct {
+	// ID of the room to be created (random if it's empty).
+	Room string `json:"room"`
+	// ID of the player taking the seat.
+	Player string `json:"player"`
+	// Number of players to be allowed in the room (only for creating).
+	Players uint8 `json:"players"`
+}
+
+// APISeatResponse from the server after the player takes a seat.
+type APISeatResponse struct {
+	Room   string `json:"room"`
+	Player string `json:"player"`
+	// Token for authenticating further requests (as `Authorization: Bearer <token>`).
+	Token string `json:"token"`
+}
+
+// APIViewResponse containing the view of the room for some player.
+type APIViewResponse struct {
+	Phase roomPhase     `json:"phase"`
+	Room  *RoomResponse `json:"room"`
+	// Player's view of the game (if it's in progress).
+	Deal *DealResponse `json:"deal,omitempty"`
+	// Sequence number of the last message sent in the room (for reading events).
+	Seq uint64 `json:"seq"`
+}
+
+// APIEventsResponse containing the messages sent to some player.
+type APIEventsResponse struct {
+	// Whether the buffer had all the messages since the requested one. If it
+	// didn't, then the client should read the view.
+	Complete bool              `json:"complete"`
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// APIErrorResponse from the server when the request fails.
+type APIErrorResponse struct {
+	Error *HandlerError `json:"error"`
+}
+
+// newToken returns a random hex token which is hard to guess.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// apiHandler serves the REST API for playing the game over plain HTTP.
+//
+//	POST   rooms                  - create a room and take a seat
+//	POST   rooms/{room}/players   - join a room and take a seat
+//	DELETE rooms/{room}/players   - leave the room
+//	POST   rooms/{room}/ready     - toggle readiness (body is `ReadyRequest`)
+//	POST   rooms/{room}/turns     - play a card (body is `TurnRequest`)
+//	GET    rooms/{room}/view      - current view of the room
+//	GET    rooms/{room}/events    - messages after the `since` sequence number
+//
+// All requests except the ones for taking a seat should be authenticated
+// with the seat's token. Paths are relative to where the handler is mounted
+// (with the prefix stripped). Seats which haven't been used for longer than
+// the session timeout are given up.
+type apiHandler struct {
+	hub  *Hub
+	lock sync.Mutex
+	// Map of the seats which have been taken to their players.
+	seats map[*seatConnection]string
+	// Ticker for expiring seats of clients which have gone away.
+	ticker *time.Ticker
+}
+
+func newAPIHandler(hub *Hub, interval time.Duration) *apiHandler {
+	return &apiHandler{
+		hub:    hub,
+		seats:  make(map[*seatConnection]string),
+		ticker: time.NewTicker(interval),
+	}
+}
+
+// watchSeats and drop the players whose seats have been idle for too long.
+//
+// **NOTE:** This must be launched into a separate goroutine.
+func (api *apiHandler) watchSeats() {
+	for {
+		select {
+		case <-api.hub.done:
+			return
+		case <-api.ticker.C:
+		}
+
+		timeout := api.hub.settings().SessionTimeout
+		expired := make(map[*seatConnection]string)
+		api.lock.Lock()
+		for c, playerID := range api.seats {
+			if c.idle(timeout) {
+				api.hub.opts.Logger.Printf("Expiring seat of player %s\n", playerID)
+				delete(api.seats, c)
+				expired[c] = playerID
+			}
+		}
+		api.lock.Unlock()
+
+		for c, playerID := range expired {
+			api.hub.dropPlayer(c, playerID)
+		}
+	}
+}
+
+// leave the seat (if it's still taken).
+func (api *apiHandler) leave(c *seatConnection, playerID string) {
+	api.lock.Lock()
+	delete(api.seats, c)
+	api.lock.Unlock()
+	api.hub.dropPlayer(c, playerID)
+}
+
+// Events corresponding to the API endpoints (for rate limiting).
+var apiEvents = map[string]string{
+	"rooms":   eventRoomCreate,
+	"players": eventPlayerJoin,
+	"ready":   eventPlayerReady,
+	"turns":   eventPlayerTurn,
+	"view":    eventResync,
+	"events":  eventReplay,
+}
+
+func (api *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "rooms" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+
+	lang := preferredLanguage(r.Header.Get("Accept-Language"))
+	var resp interface{}
+	var e *HandlerError
+	event := apiEvents[parts[len(parts)-1]]
+	limits := api.hub.settings().AddrRateLimits
+	if ok, wait := api.hub.addrLimiter(api.hub.clientAddr(r)).allow(event, limits, time.Now()); !ok {
+		e = rateLimited(event, wait)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	} else if len(parts) == 1 && r.Method == http.MethodPost {
+		resp, e = api.takeSeat(r, "", true)
+	} else if len(parts) == 3 && parts[2] == "players" && r.Method == http.MethodPost {
+		resp, e = api.takeSeat(r, parts[1], false)
+	} else if len(parts) == 3 {
+		resp, e = api.seatAction(r, parts[1], parts[2])
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if e != nil {
+		e.localize(lang)
+		w.WriteHeader(e.Code.httpStatus())
+		json.NewEncoder(w).Encode(&APIErrorResponse{Error: e})
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readBody of the request (limited in size).
+func (api *apiHandler) readBody(r *http.Request) (*json.RawMessage, *HandlerError) {
+	body, err := readFrame(r.Body, api.hub.opts.MaxMessageBytes)
+	if err == errFrameTooLarge {
+		return nil, messageTooLarge(api.hub.opts.MaxMessageBytes)
+	}
+
+	if err != nil || !json.Valid(body) {
+		return nil, invalidMessage("", "", reasonMalformed)
+	}
+
+	data := json.RawMessage(body)
+	return &data, nil
+}
+
+// takeSeat in a new (or existing) room for the player.
+func (api *apiHandler) takeSeat(r *http.Request, roomID string, create bool) (interface{}, *HandlerError) {
+	data, e := api.readBody(r)
+	if e != nil {
+		return nil, e
+	}
+
+	event := eventPlayerJoin
+	if create {
+		event = eventRoomCreate
+	}
+
+	if e = validateData(event, "", apiSeatSchema, data); e != nil {
+		return nil, e
+	}
+
+	var req APIJoinRequest
+	json.Unmarshal(*data, &req)
+	playerID := strings.ToLower(strings.TrimSpace(req.Player))
+	if playerID == "" {
+		return nil, &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidRequest,
+		}
+	}
+
+	token, err := newToken()
+	if err != nil {
+		api.hub.opts.Logger.Printf("Unable to create token: %v\n", err)
+		return nil, &HandlerError{
+			Code: errInternal,
+			key:  msgInternal,
+		}
+	}
+
+	conn := &seatConnection{token: token, lastSeen: time.Now()}
+	sess := &session{
+		addr:     api.hub.clientAddr(r),
+		lang:     preferredLanguage(r.Header.Get("Accept-Language")),
+		encoding: encodingJSON,
+		playerID: playerID,
+	}
+
+	if create {
+		roomID = strings.TrimSpace(req.Room)
+		if roomID == "" {
+			roomID = randSeq(16)
+		}
+
+		e = api.hub.createRoomWithPlayer(conn, roomID, playerID, sess, data)
+	} else {
+		e = api.hub.addPlayer(conn, roomID, playerID, sess)
+	}
+
+	if e != nil {
+		return nil, e
+	}
+
+	api.lock.Lock()
+	api.seats[conn] = playerID
+	api.lock.Unlock()
+	return &APISeatResponse{
+		Room:   roomID,
+		Player: playerID,
+		Token:  token,
+	}, nil
+}
+
+// authenticate the request for the given room. Returns the player who owns
+// the seat (unless they've left the room).
+//
+// **NOTE:** The caller is responsible for synchronizing access to room pointer.
+func authenticate(r *http.Request, room *Room) *Player {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer"))
+	if token == "" {
+		return nil
+	}
+
+	for _, p := range room.players {
+		seat, ok := p.conn.(*seatConnection)
+		if ok && !p.left && subtle.ConstantTimeCompare([]byte(seat.token), []byte(token)) == 1 {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// seatAction performs the action for the seat authenticated by the request.
+func (api *apiHandler) seatAction(r *http.Request, roomID, action string) (interface{}, *HandlerError) {
+	room, exists := api.hub.getRoom(roomID)
+	if !exists {
+		return nil, &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgRoomMissingCreate,
+			args:    []interface{}{roomID},
+			Event:   eventRoomMissing,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	player := authenticate(r, room)
+	room.lock.Unlock()
+	if player == nil {
+		return nil, &HandlerError{
+			Code:    errNotInRoom,
+			key:     msgNotInRoom,
+			args:    []interface{}{roomID},
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	seat := player.conn.(*seatConnection)
+	seat.touch()
+	var data *json.RawMessage
+	var e *HandlerError
+	if r.Method == http.MethodPost {
+		if data, e = api.readBody(r); e != nil {
+			return nil, e
+		}
+
+		if schema, exists := eventSchemas[apiEvents[action]]; exists {
+			if e = validateData(apiEvents[action], "", schema.data, data); e != nil {
+				return nil, e
+			}
+		}
+	}
+
+	if action == "players" && r.Method == http.MethodDelete {
+		api.leave(seat, player.id)
+	} else if action == "ready" && r.Method == http.MethodPost {
+		e = api.hub.setPlayerReady(player.conn, roomID, player.id, data)
+	} else if action == "turns" && r.Method == http.MethodPost {
+		e = api.hub.validateAndApplyTurn(player.conn, roomID, player.id, data)
+	} else if action == "view" && r.Method == http.MethodGet {
+		return roomView(room, player), nil
+	} else if action == "events" && r.Method == http.MethodGet {
+		since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		return roomEvents(room, player, since), nil
+	} else {
+		return nil, &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidRequest,
+		}
+	}
+
+	if e != nil {
+		return nil, e
+	}
+
+	return roomView(room, player), nil
+}
+
+// roomView for the given player.
+func roomView(room *Room, player *Player) *APIViewResponse {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	view := &APIViewResponse{
+		Phase: room.phase,
+		Room:  room.roomResponse(),
+		Seq:   room.seq,
+	}
+
+	if room.inGame() {
+		view.Deal = room.dealResponse(player.id, room.turnPlayerID())
+	}
+
+	return view
+}
+
+// roomEvents sent to the given player after the given sequence number.
+func roomEvents(room *Room, player *Player, since uint64) *APIEventsResponse {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	messages, complete := room.messagesSince(player.id, since)
+	resp := &APIEventsResponse{
+		Complete: complete,
+		Messages: make([]json.RawMessage, 0, len(messages)),
+	}
+
+	for _, m := range messages {
+		resp.Messages = append(resp.Messages, json.RawMessage(m))
+	}
+
+	return resp
+}
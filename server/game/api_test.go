@@ -0,0 +1,120 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESTAPI(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour})
+	defer hub.Close()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	request := func(method, path, token, body string, resp interface{}) int {
+		req, _ := http.NewRequest(method, server.URL+"/api"+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		r, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		defer r.Body.Close()
+		json.NewDecoder(r.Body).Decode(resp)
+		return r.StatusCode
+	}
+
+	seats := make([]APISeatResponse, 3)
+	assert.Equal(http.StatusOK, request("POST", "/rooms", "", `{"room":"attic","player":"Alice","players":3}`, &seats[0]))
+	assert.Equal("attic", seats[0].Room)
+	assert.Equal("alice", seats[0].Player)
+	assert.Len(seats[0].Token, 32)
+	assert.Equal(http.StatusOK, request("POST", "/rooms/attic/players", "", `{"player":"bob"}`, &seats[1]))
+	assert.Equal(http.StatusOK, request("POST", "/rooms/attic/players", "", `{"player":"carol"}`, &seats[2]))
+
+	var errResp APIErrorResponse
+	assert.Equal(http.StatusConflict, request("POST", "/rooms/attic/players", "", `{"player":"dave"}`, &errResp))
+	assert.Equal(errRoomFull, errResp.Error.Code)
+	assert.Equal(http.StatusNotFound, request("GET", "/rooms/cellar/view", seats[0].Token, "", &errResp))
+	errResp = APIErrorResponse{}
+	assert.Equal(http.StatusBadRequest, request("POST", "/rooms", "", `{"room":"cellar","player":7}`, &errResp))
+	assert.Equal(errInvalidRequest, errResp.Error.Code)
+	assert.Equal(map[string]interface{}{"event": eventRoomCreate, "field": "player", "reason": reasonType}, errResp.Error.Details)
+	assert.Equal(http.StatusRequestEntityTooLarge, request("POST", "/rooms", "", strings.Repeat(" ", maxMessageBytes+1), &errResp))
+
+	// Seats can't be used without their tokens.
+	assert.Equal(http.StatusForbidden, request("GET", "/rooms/attic/view", "", "", &errResp))
+	assert.Equal(http.StatusForbidden, request("GET", "/rooms/attic/view", "nope", "", &errResp))
+	assert.Equal(errNotInRoom, errResp.Error.Code)
+
+	var view APIViewResponse
+	for _, s := range seats {
+		assert.Equal(http.StatusOK, request("POST", "/rooms/attic/ready", s.Token, `{"ready":true}`, &view))
+	}
+
+	assert.Equal(phaseDealing, view.Phase)
+	assert.ElementsMatch([]string{"alice", "bob", "carol"}, view.Room.Ready)
+
+	// Playing before the countdown ends isn't allowed.
+	assert.Equal(http.StatusConflict, request("POST", "/rooms/attic/turns", seats[0].Token, `{"card":{"label":"A","suite":"s"}}`, &errResp))
+	assert.Equal(errInvalidPhase, errResp.Error.Code)
+
+	var events APIEventsResponse
+	assert.Equal(http.StatusOK, request("GET", "/rooms/attic/events?since=0", seats[1].Token, "", &events))
+	assert.True(events.Complete)
+	assert.NotEmpty(events.Messages)
+
+	// Other connections can't act for the seats.
+	conn := &memConnection{}
+	sess := &session{encoding: encodingJSON, features: []string{}}
+	for _, event := range []string{eventPlayerMsg, eventPlayerReady, eventNewGameRequest} {
+		data := json.RawMessage(`{"ready":false}`)
+		hub.handle(conn, sess, &GameMessage{Event: event, Player: "alice", Room: "attic", Msg: "hi", Data: &data})
+		sent := conn.take()
+		assert.Len(sent, 1)
+		assert.Equal(errNotInRoom, sent[0].Error.Code)
+	}
+
+	assert.Equal(http.StatusBadRequest, request("POST", "/rooms/attic/ready", seats[1].Token, `{`, &errResp))
+	assert.Equal(http.StatusNotFound, request("GET", "/games", "", "", &errResp))
+}
+
+func TestAPISeatExpiry(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: 10 * time.Millisecond, SessionTimeout: 50 * time.Millisecond})
+	defer hub.Close()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/rooms", "application/json", strings.NewReader(`{"room":"attic","player":"alice","players":3}`))
+	assert.Nil(err)
+	var seat APISeatResponse
+	json.NewDecoder(resp.Body).Decode(&seat)
+	resp.Body.Close()
+
+	room, _ := hub.getRoom("attic")
+	left := func() bool {
+		room.lock.Lock()
+		defer room.lock.Unlock()
+		return room.players["alice"].left
+	}
+
+	assert.False(left())
+	assert.Eventually(left, time.Second, 10*time.Millisecond)
+
+	// The token can't be used after the seat has been given up.
+	req, _ := http.NewRequest("GET", server.URL+"/api/rooms/attic/view", nil)
+	req.Header.Set("Authorization", "Bearer "+seat.Token)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,214 @@
+package game
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+const (
+	// Default limit for rooms in the server.
+	maxRooms = 1000
+	// Default limit for rooms created from some client address.
+	maxRoomsPerAddr = 10
+	// Default limit for connections from some client address.
+	maxConnsPerAddr = 20
+)
+
+// Limits which are reported in `ServerFullDetails`.
+const (
+	limitRooms     = "rooms"
+	limitAddrRooms = "addrRooms"
+	limitAddrConns = "addrConnections"
+)
+
+// ServerFullDetails for `errServerFull`.
+type ServerFullDetails struct {
+	// Limit which has been reached.
+	Limit string `json:"limit"`
+	// Position in the queue for creating rooms (if the player has been queued).
+	Position int `json:"position,omitempty"`
+}
+
+// queuedRoom is a request for creating a room, which is waiting for
+// some other room to be removed.
+type queuedRoom struct {
+	conn connection
+	// Session of the connection, which the player may still be changing (e.g.,
+	// their locale) while the room is being admitted on another goroutine.
+	// Its language and negotiated settings are only read under its lock.
+	sess             *session
+	roomID, playerID string
+	data             *json.RawMessage
+	// Whether this request is being admitted.
+	admitting bool
+}
+
+// capacity tracks the rooms and connections for enforcing the limits.
+type capacity struct {
+	lock sync.Mutex
+	// Number of rooms in the server.
+	rooms int
+	// Number of rooms created from each client address.
+	addrRooms map[string]int
+	// Number of connections from each client address.
+	addrConns map[string]int
+	// Requests for creating rooms (in the order of arrival).
+	queue []*queuedRoom
+}
+
+func newCapacity(rooms int) *capacity {
+	return &capacity{
+		rooms:     rooms,
+		addrRooms: make(map[string]int),
+		addrConns: make(map[string]int),
+	}
+}
+
+// serverFull returns the error for the given limit.
+func serverFull(limit string, position int) *HandlerError {
+	e := &HandlerError{
+		Code:    errServerFull,
+		key:     msgServerFull,
+		Event:   eventServerFull,
+		Details: &ServerFullDetails{Limit: limit, Position: position},
+	}
+
+	if limit == limitAddrRooms {
+		e.key = msgTooManyRooms
+	} else if limit == limitAddrConns {
+		e.key = msgTooManyConnections
+	} else if position > 0 {
+		e.key, e.args = msgServerQueued, []interface{}{position}
+	}
+
+	return e
+}
+
+// addConnection from the given address (if it hasn't hit the limit).
+func (hub *Hub) addConnection(addr string) *HandlerError {
+	max := hub.settings().MaxConnsPerAddr
+	c := hub.capacity
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.addrConns[addr] >= max {
+		hub.opts.Logger.Printf("Refusing connection from %s (limit reached).\n", addr)
+		return serverFull(limitAddrConns, 0)
+	}
+
+	c.addrConns[addr]++
+	return nil
+}
+
+// removeConnection from the given address.
+func (hub *Hub) removeConnection(addr string) {
+	c := hub.capacity
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.addrConns[addr]--; c.addrConns[addr] <= 0 {
+		delete(c.addrConns, addr)
+	}
+}
+
+// reserveRoom for the player creating it. If the server is full and there's
+// a queue, then the request is queued and created later (when some room
+// is removed).
+func (hub *Hub) reserveRoom(conn connection, roomID, playerID string, sess *session, data *json.RawMessage) *HandlerError {
+	opts := hub.settings()
+	c := hub.capacity
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.addrRooms[sess.addr] >= opts.MaxRoomsPerAddr {
+		return serverFull(limitAddrRooms, 0)
+	}
+
+	// Queued players go first.
+	admitted := len(c.queue) > 0 && c.queue[0].conn == conn
+	if c.rooms >= opts.MaxRooms || (len(c.queue) > 0 && !admitted) {
+		// Seats in the REST API can't be told when they've been admitted.
+		_, isSeat := conn.(*seatConnection)
+		if isSeat || len(c.queue) >= opts.RoomQueue {
+			return serverFull(limitRooms, 0)
+		}
+
+		for i, q := range c.queue {
+			if q.conn == conn {
+				return serverFull(limitRooms, i+1)
+			}
+		}
+
+		hub.opts.Logger.Printf("Queueing room %s from player %s.\n", roomID, playerID)
+		c.queue = append(c.queue, &queuedRoom{
+			conn:     conn,
+			sess:     sess,
+			roomID:   roomID,
+			playerID: playerID,
+			data:     data,
+		})
+		return serverFull(limitRooms, len(c.queue))
+	}
+
+	if admitted {
+		c.queue = c.queue[1:]
+	}
+
+	c.rooms++
+	c.addrRooms[sess.addr]++
+	return nil
+}
+
+// releaseRoom after it's been removed and let the next player in the queue
+// (if any) create their room.
+func (hub *Hub) releaseRoom(room *Room) {
+	c := hub.capacity
+	c.lock.Lock()
+	c.rooms--
+	if c.addrRooms[room.creator]--; c.addrRooms[room.creator] <= 0 {
+		delete(c.addrRooms, room.creator)
+	}
+	c.lock.Unlock()
+
+	hub.admitNext()
+}
+
+// unqueue the request from the given connection (if any).
+func (hub *Hub) unqueue(conn connection) {
+	c := hub.capacity
+	c.lock.Lock()
+	for i, q := range c.queue {
+		if q.conn == conn {
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+			break
+		}
+	}
+	c.lock.Unlock()
+
+	hub.admitNext()
+}
+
+// admitNext player in the queue if there's room for them.
+func (hub *Hub) admitNext() {
+	opts := hub.settings()
+	c := hub.capacity
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.queue) == 0 || c.queue[0].admitting || c.rooms >= opts.MaxRooms {
+		return
+	}
+
+	q := c.queue[0]
+	q.admitting = true
+	go hub.admit(q)
+}
+
+// admit the queued request by creating its room.
+//
+// **NOTE:** This must be launched into a separate goroutine (since the
+// room is removed from the hub's goroutine).
+func (hub *Hub) admit(q *queuedRoom) {
+	hub.opts.Logger.Printf("Admitting room %s from player %s.\n", q.roomID, q.playerID)
+	e := hub.createRoomWithPlayer(q.conn, q.roomID, q.playerID, q.sess, q.data)
+	hub.sendError(q.conn, q.roomID, q.sess, "", e)
+	// The request may not have needed a room after all (e.g., if someone
+	// else created a room with that name in the meantime).
+	hub.unqueue(q.conn)
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestRoomLimits(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour, MaxRooms: 2, MaxRoomsPerAddr: 1})
+	defer hub.Close()
+	data := json.RawMessage(`{"players":3}`)
+	create := func(addr, roomID string) *HandlerError {
+		sess := &session{addr: addr, encoding: encodingJSON}
+		return hub.createRoomWithPlayer(&memConnection{}, roomID, "player1", sess, &data)
+	}
+
+	assert.Nil(create("192.0.2.1", "attic"))
+	e := create("192.0.2.1", "cellar")
+	assert.Equal(errServerFull, e.Code)
+	assert.Equal(eventServerFull, e.Event)
+	assert.Equal(&ServerFullDetails{Limit: limitAddrRooms}, e.Details)
+
+	assert.Nil(create("192.0.2.2", "cellar"))
+	e = create("192.0.2.3", "garage")
+	assert.Equal(&ServerFullDetails{Limit: limitRooms}, e.Details)
+
+	// Joining existing rooms doesn't need capacity.
+	sess := &session{addr: "192.0.2.3", encoding: encodingJSON}
+	assert.Nil(hub.addPlayer(&memConnection{}, "attic", "player2", sess))
+
+	room, _ := hub.getRoom("attic")
+	hub.releaseRoom(room)
+	assert.Nil(create("192.0.2.3", "garage"))
+}
+
+func TestConcurrentRoomCreation(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour, MaxRooms: 1000, MaxRoomsPerAddr: 1000})
+	defer hub.Close()
+	data := json.RawMessage(`{"players":6}`)
+	for i := 0; i < 500; i++ {
+		roomID := fmt.Sprintf("room%d", i)
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for j := 0; j < 6; j++ {
+			wg.Add(1)
+			go func(playerID string) {
+				defer wg.Done()
+				<-start
+				sess := &session{addr: "192.0.2.1", encoding: encodingJSON}
+				assert.Nil(hub.createRoomWithPlayer(&memConnection{}, roomID, playerID, sess, &data))
+			}(fmt.Sprintf("player%d", j))
+		}
+
+		close(start)
+		wg.Wait()
+		room, _ := hub.getRoom(roomID)
+		room.lock.Lock()
+		assert.Len(room.players, 6)
+		room.lock.Unlock()
+	}
+
+	// Only the rooms which have been created are counted.
+	assert.Equal(500, hub.capacity.rooms)
+	assert.Equal(500, hub.capacity.addrRooms["192.0.2.1"])
+}
+
+func TestRoomQueue(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour, MaxRooms: 1, RoomQueue: 1})
+	defer hub.Close()
+	data := json.RawMessage(`{"players":3}`)
+	conns := []*memConnection{{}, {}, {}}
+	create := func(i int, roomID string) *HandlerError {
+		sess := &session{addr: roomID, encoding: encodingJSON}
+		return hub.createRoomWithPlayer(conns[i], roomID, "player1", sess, &data)
+	}
+
+	assert.Nil(create(0, "attic"))
+	e := create(1, "cellar")
+	assert.Equal(&ServerFullDetails{Limit: limitRooms, Position: 1}, e.Details)
+	e = create(2, "garage")
+	assert.Equal(&ServerFullDetails{Limit: limitRooms}, e.Details)
+
+	// Queued players get their rooms when some room is removed.
+	room, _ := hub.getRoom("attic")
+	hub.releaseRoom(room)
+	assert.Eventually(func() bool {
+		_, exists := hub.getRoom("cellar")
+		return exists
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Eventually(func() bool {
+		for _, m := range conns[1].take() {
+			if m.Event == eventPlayerJoin {
+				return true
+			}
+		}
+
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	// Players who leave the queue don't hold up the others.
+	assert.NotNil(create(2, "garage"))
+	hub.dropPlayer(conns[2], "player1")
+	hub.capacity.lock.Lock()
+	assert.Empty(hub.capacity.queue)
+	hub.capacity.lock.Unlock()
+}
+
+func TestQueuedSessionChanges(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour, MaxRooms: 1, RoomQueue: 1})
+	defer hub.Close()
+	data := json.RawMessage(`{"players":3}`)
+	sess := &session{addr: "192.0.2.1", lang: "en", encoding: encodingJSON}
+	conn := &memConnection{}
+	assert.Nil(hub.createRoomWithPlayer(&memConnection{}, "attic", "player1", sess, &data))
+	assert.NotNil(hub.createRoomWithPlayer(conn, "cellar", "player1", sess, &data))
+
+	// Player can keep using the connection while their room is being admitted.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, l := range []string{"de", "fr", "hi"} {
+			hub.handle(conn, sess, &GameMessage{Event: eventHello, Locale: l})
+		}
+	}()
+
+	room, _ := hub.getRoom("attic")
+	hub.releaseRoom(room)
+	wg.Wait()
+
+	assert.Eventually(func() bool {
+		_, exists := hub.getRoom("cellar")
+		return exists
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal("hi", sess.language())
+}
+
+func TestConnectionLimits(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour, MaxConnsPerAddr: 1})
+	defer hub.Close()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	first, err := websocket.Dial(wsURL, "", server.URL)
+	assert.Nil(err)
+	websocket.Message.Send(first, `{"event":"Hello","data":{"version":2}}`)
+	var msg GameMessage
+	assert.Nil(receiveMessage(first, &msg))
+
+	second, err := websocket.Dial(wsURL, "", server.URL)
+	assert.Nil(err)
+	assert.Nil(receiveMessage(second, &msg))
+	assert.Equal(eventServerFull, msg.Event)
+	assert.Equal(errServerFull, msg.Error.Code)
+	assert.NotNil(receiveMessage(second, &msg))
+
+	// Connections can be made again once the others have been closed.
+	first.Close()
+	assert.Eventually(func() bool {
+		hub.capacity.lock.Lock()
+		defer hub.capacity.lock.Unlock()
+		return hub.capacity.addrConns["127.0.0.1"] == 0
+	}, time.Second, 10*time.Millisecond)
+
+	resp, err := server.Client().Post(server.URL+"/http/connect", "application/json", nil)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(200, resp.StatusCode)
+	resp, err = server.Client().Post(server.URL+"/http/connect", "application/json", nil)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(503, resp.StatusCode)
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	"encoding/json"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// connection to some client (over any transport).
+type connection interface {
+	// send the JSON-encoded message to the client.
+	send(data []byte) error
+}
+
+// wsConnection sends messages over a websocket.
+type wsConnection struct {
+	ws *websocket.Conn
+	// Settings of this connection (for the negotiated encoding).
+	sess *session
+}
+
+func (c *wsConnection) send(data []byte) error {
+	enc, _ := c.sess.negotiated()
+	return sendEncoded(c.ws, enc, data)
+}
+
+// memConnection keeps messages in memory (for tests and clients
+// embedded in the same process, like bots).
+type memConnection struct {
+	lock sync.Mutex
+	// Messages sent to this connection so far.
+	outbox [][]byte
+	// Called for each message (if set).
+	onMessage func(data []byte)
+}
+
+func (c *memConnection) send(data []byte) error {
+	c.lock.Lock()
+	c.outbox = append(c.outbox, data)
+	onMessage := c.onMessage
+	c.lock.Unlock()
+
+	if onMessage != nil {
+		onMessage(data)
+	}
+
+	return nil
+}
+
+// take all messages sent to this connection (decoded).
+func (c *memConnection) take() []GameMessage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	messages := make([]GameMessage, 0, len(c.outbox))
+	for _, data := range c.outbox {
+		var msg GameMessage
+		if err := json.Unmarshal(data, &msg); err == nil {
+			messages = append(messages, msg)
+		}
+	}
+
+	c.outbox = nil
+	return messages
+}
@@ -0,0 +1,188 @@
+package game
+
+// DeltaResponse from the server containing the changes in the game since
+// the previous deal (for players who've enabled the "deltas" feature).
+type DeltaResponse struct {
+	// Sequence number of the deal (or delta) message on which these changes
+	// should be applied. If the client hasn't seen that one, then it should
+	// ask for a resync.
+	Base uint64 `json:"base"`
+	// Cards added to the table (or the whole table if it's been reset).
+	Table []PlayerCard `json:"table"`
+	// Whether the table has been cleared before adding these cards.
+	TableReset bool `json:"tableReset"`
+	// Cards added to (and removed from) the player's hand.
+	HandAdded   []Card `json:"handAdded"`
+	HandRemoved []Card `json:"handRemoved"`
+	// Whether this player is the dealer for this round.
+	IsDealer bool `json:"isDealer"`
+	// Whether this is the receiving player's turn.
+	OurTurn bool `json:"ourTurn"`
+	// Whose turn is this?
+	TurnPlayer string `json:"turnPlayer"`
+	// Number of cards in the hands of other players (only for the ones which have changed).
+	OpponentHands map[string]uint8 `json:"opponentHands"`
+	// Cards added to the discarded pile (or the whole pile if it's been reset).
+	Discarded      []Card `json:"discarded"`
+	DiscardedReset bool   `json:"discardedReset"`
+	// Ace cards added to the collection (or the whole collection if it's been reset).
+	AceCards      []Card `json:"aceCards"`
+	AceCardsReset bool   `json:"aceCardsReset"`
+	// Cards which this player is allowed to submit (if it's their turn).
+	LegalCards []Card `json:"legalCards"`
+	// Current turn in the room (for submitting cards).
+	Turn uint64 `json:"turn"`
+}
+
+// sendDeal sends the current view of the game to the player. Players who've
+// enabled deltas get only the changes since the previous deal, unless `full`
+// is set (or they haven't received anything yet).
+func (r *Room) sendDeal(p *Player, turnPlayerID string, full bool) {
+	deal := r.dealResponse(p.id, turnPlayerID)
+	msg := &GameMessage{
+		Player:   p.id,
+		Room:     p.roomID,
+		Event:    eventPlayerTurn,
+		Response: deal,
+	}
+
+	if p.deltas && !full && p.lastDeal != nil {
+		msg.Event = eventPlayerTurnDelta
+		msg.Response = diffDeals(p.lastDeal, deal, p.lastDealSeq)
+	}
+
+	r.send(p, msg)
+	if p.deltas {
+		p.lastDeal = copyDeal(deal)
+		p.lastDealSeq = msg.Seq
+	}
+}
+
+// diffDeals returns the changes between two deals sent to some player.
+func diffDeals(prev, next *DealResponse, base uint64) *DeltaResponse {
+	delta := &DeltaResponse{
+		Base:          base,
+		IsDealer:      next.IsDealer,
+		OurTurn:       next.OurTurn,
+		TurnPlayer:    next.TurnPlayer,
+		OpponentHands: make(map[string]uint8),
+		LegalCards:    next.LegalCards,
+		Turn:          next.Turn,
+	}
+
+	delta.Table, delta.TableReset = appendedTable(prev.Table, next.Table)
+	delta.Discarded, delta.DiscardedReset = appendedCards(prev.Discarded, next.Discarded)
+	delta.AceCards, delta.AceCardsReset = appendedCards(prev.AceCards, next.AceCards)
+	delta.HandAdded = missingCards(next.Hand, prev.Hand)
+	delta.HandRemoved = missingCards(prev.Hand, next.Hand)
+
+	for id, n := range next.OpponentHands {
+		if m, exists := prev.OpponentHands[id]; !exists || m != n {
+			delta.OpponentHands[id] = n
+		}
+	}
+
+	return delta
+}
+
+// appendedTable returns the cards added to the table, or the whole table
+// (along with `true`) if the previous one isn't its prefix.
+func appendedTable(prev, next []PlayerCard) ([]PlayerCard, bool) {
+	if len(prev) > len(next) {
+		return next, true
+	}
+
+	for i := range prev {
+		if prev[i] != next[i] {
+			return next, true
+		}
+	}
+
+	return next[len(prev):], false
+}
+
+// appendedCards returns the cards added to the pile, or the whole pile
+// (along with `true`) if the previous one isn't its prefix.
+func appendedCards(prev, next []Card) ([]Card, bool) {
+	if len(prev) > len(next) {
+		return next, true
+	}
+
+	for i := range prev {
+		if prev[i] != next[i] {
+			return next, true
+		}
+	}
+
+	return next[len(prev):], false
+}
+
+// missingCards returns the cards in `a` which aren't in `b`.
+func missingCards(a, b []Card) []Card {
+	counts := make(map[Card]int)
+	for _, c := range b {
+		counts[c]++
+	}
+
+	missing := make([]Card, 0)
+	for _, c := range a {
+		if counts[c] > 0 {
+			counts[c]--
+		} else {
+			missing = append(missing, c)
+		}
+	}
+
+	return missing
+}
+
+// copyDeal so that later changes in the room don't affect it. Hands are
+// modified in place when cards are removed.
+func copyDeal(d *DealResponse) *DealResponse {
+	c := *d
+	c.Table = append([]PlayerCard(nil), d.Table...)
+	c.Hand = append([]Card(nil), d.Hand...)
+	c.Discarded = append([]Card(nil), d.Discarded...)
+	c.AceCards = append([]Card(nil), d.AceCards...)
+	c.OpponentHands = make(map[string]uint8, len(d.OpponentHands))
+	for id, n := range d.OpponentHands {
+		c.OpponentHands[id] = n
+	}
+
+	return &c
+}
+
+// resyncPlayer sends the whole view of the game to the player (for
+// clients which have missed some changes).
+func (hub *Hub) resyncPlayer(conn connection, roomID, playerID string) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgRoomMissingCreate,
+			args:    []interface{}{roomID},
+			Event:   eventRoomMissing,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	defer room.lock.Unlock()
+
+	player, exists := room.players[playerID]
+	if !exists || player.conn != conn {
+		return &HandlerError{
+			Code:    errNotInRoom,
+			key:     msgNotInRoom,
+			args:    []interface{}{roomID},
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	if e := room.checkEventPhase(eventResync); e != nil {
+		return e
+	}
+
+	room.sendDeal(player, room.turnPlayerID(), true)
+	return nil
+}
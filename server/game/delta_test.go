@@ -0,0 +1,64 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDeals(t *testing.T) {
+	assert := assert.New(t)
+	prev := &DealResponse{
+		Table:         []PlayerCard{PlayerCard{"player1", Card{"6", "s"}}},
+		Hand:          []Card{Card{"3", "s"}, Card{"K", "d"}, Card{"J", "s"}},
+		TurnPlayer:    "player2",
+		OurTurn:       true,
+		OpponentHands: map[string]uint8{"player1": 1, "player3": 1},
+		Discarded:     []Card{Card{"2", "c"}},
+		AceCards:      []Card{Card{"A", "s"}},
+		Turn:          4,
+	}
+
+	saved := copyDeal(prev)
+	next := &DealResponse{
+		Table:         append(prev.Table, PlayerCard{"player2", Card{"J", "s"}}),
+		Hand:          []Card{Card{"3", "s"}, Card{"K", "d"}},
+		TurnPlayer:    "player3",
+		OpponentHands: map[string]uint8{"player1": 1, "player3": 1},
+		Discarded:     prev.Discarded,
+		AceCards:      []Card{},
+		LegalCards:    []Card{},
+		Turn:          5,
+	}
+
+	// Hands are modified in place, so the saved deal shouldn't change.
+	prev.Hand[0] = Card{"J", "s"}
+	assert.Equal(Card{"3", "s"}, saved.Hand[0])
+
+	delta := diffDeals(saved, next, 42)
+	assert.EqualValues(42, delta.Base)
+	assert.Equal([]PlayerCard{PlayerCard{"player2", Card{"J", "s"}}}, delta.Table)
+	assert.False(delta.TableReset)
+	assert.Empty(delta.HandAdded)
+	assert.Equal([]Card{Card{"J", "s"}}, delta.HandRemoved)
+	assert.Equal("player3", delta.TurnPlayer)
+	assert.False(delta.OurTurn)
+	assert.Empty(delta.OpponentHands)
+	assert.Empty(delta.Discarded)
+	assert.False(delta.DiscardedReset)
+	assert.Empty(delta.AceCards)
+	assert.True(delta.AceCardsReset)
+	assert.EqualValues(5, delta.Turn)
+
+	// Someone picks up the table.
+	picked := copyDeal(next)
+	picked.Table = []PlayerCard{}
+	picked.Hand = append(picked.Hand, Card{"6", "s"}, Card{"J", "s"})
+	picked.OpponentHands["player1"] = 0
+	delta = diffDeals(next, picked, 43)
+	assert.Empty(delta.Table)
+	assert.True(delta.TableReset)
+	assert.Equal([]Card{Card{"6", "s"}, Card{"J", "s"}}, delta.HandAdded)
+	assert.Empty(delta.HandRemoved)
+	assert.Equal(map[string]uint8{"player1": 0}, delta.OpponentHands)
+}
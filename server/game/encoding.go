@@ -0,0 +1,407 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+
+	"golang.org/x/net/websocket"
+)
+
+// wireEncoding used for messages in some connection.
+type wireEncoding string
+
+const (
+	// Messages are sent as JSON in text frames (default).
+	encodingJSON wireEncoding = "json"
+	// Messages are sent as MessagePack in binary frames.
+	encodingMsgpack wireEncoding = "msgpack"
+)
+
+var (
+	// Frame couldn't be decoded as JSON or MessagePack.
+	errMalformedFrame = errors.New("malformed frame")
+	// Frame is larger than what's allowed.
+	errFrameTooLarge = websocket.ErrFrameTooLarge
+)
+
+// Encodings supported by this server (in the order of our preference).
+var serverEncodings = []wireEncoding{encodingMsgpack, encodingJSON}
+
+// sendMessage encodes the given message and sends it to the connection.
+func sendMessage(conn connection, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return conn.send(data)
+}
+
+// sendEncoded sends the JSON-encoded message to the connection in the given encoding.
+func sendEncoded(ws *websocket.Conn, enc wireEncoding, data []byte) error {
+	if enc != encodingMsgpack {
+		return websocket.Message.Send(ws, string(data))
+	}
+
+	packed, err := jsonToMsgpack(data)
+	if err != nil {
+		return err
+	}
+
+	// Byte slices go out in binary frames.
+	return websocket.Message.Send(ws, packed)
+}
+
+// receiveMessage from the connection in whatever encoding the client has used.
+func receiveMessage(ws *websocket.Conn, msg *GameMessage) error {
+	var frame []byte
+	if err := websocket.Message.Receive(ws, &frame); err != nil {
+		return err
+	}
+
+	return decodeMessage(frame, msg)
+}
+
+// readFrame from the reader (like a request body), up to the given size.
+func readFrame(r io.Reader, limit int) ([]byte, error) {
+	frame, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(frame) > limit {
+		return nil, errFrameTooLarge
+	}
+
+	return frame, nil
+}
+
+// decodeMessage from a frame. JSON messages are always objects, so anything
+// else is treated as MessagePack.
+func decodeMessage(frame []byte, msg *GameMessage) error {
+	// Whitespace is only skipped for finding the type, since its bytes are
+	// valid numbers in MessagePack.
+	text := bytes.TrimLeft(frame, " \t\r\n")
+	if len(text) == 0 || text[0] != '{' {
+		data, err := msgpackToJSON(frame)
+		if err != nil {
+			return errMalformedFrame
+		}
+
+		frame = data
+	}
+
+	if json.Unmarshal(frame, msg) != nil {
+		return errMalformedFrame
+	}
+
+	return nil
+}
+
+/* MessagePack support for JSON-compatible values. */
+
+// jsonToMsgpack converts the JSON-encoded value to MessagePack.
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := packValue(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// msgpackToJSON converts the MessagePack-encoded value to JSON.
+func msgpackToJSON(data []byte) ([]byte, error) {
+	r := bytes.NewReader(data)
+	v, err := unpackValue(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Len() > 0 {
+		return nil, errors.New("msgpack: trailing bytes after value")
+	}
+
+	return json.Marshal(v)
+}
+
+// packValue writes the value (as decoded from JSON) in MessagePack.
+func packValue(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			packInt(buf, i)
+			break
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.Write([]byte{0xd9, byte(n)})
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdb)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+
+		buf.WriteString(v)
+	case []interface{}:
+		packHeader(buf, len(v), 0x90, 0xdc)
+		for _, e := range v {
+			if err := packValue(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// Sort the keys so that the same message always gives the same bytes.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		packHeader(buf, len(v), 0x80, 0xde)
+		for _, k := range keys {
+			packValue(buf, k)
+			if err := packValue(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+
+	return nil
+}
+
+// packInt writes the integer in the smallest possible format.
+func packInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= 0 && i <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(i)})
+	case i >= 0 && i <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(i))
+	case i >= 0:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, uint64(i))
+	case i >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(int8(i))})
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// packHeader writes the header for an array or a map with the given length.
+func packHeader(buf *bytes.Buffer, n int, fix, code16 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code16 + 1)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// unpackValue reads a MessagePack value into its JSON-compatible equivalent.
+func unpackValue(r *bytes.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0xa0 && c <= 0xbf:
+		return unpackString(r, int(c&0x1f))
+	case c >= 0x90 && c <= 0x9f:
+		return unpackArray(r, int(c&0x0f))
+	case c >= 0x80 && c <= 0x8f:
+		return unpackMap(r, int(c&0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := unpackUint(r, 1<<(c-0xcc))
+		if n > math.MaxInt64 {
+			return float64(n), err
+		}
+
+		return int64(n), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := unpackUint(r, size)
+		// Sign-extend from the actual width.
+		shift := uint(64 - 8*size)
+		return int64(n<<shift) >> shift, err
+	case 0xca:
+		n, err := unpackUint(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := unpackUint(r, 8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := unpackUint(r, 1<<(c-0xd9))
+		if err != nil {
+			return nil, err
+		}
+
+		return unpackString(r, int(n))
+	case 0xc4, 0xc5, 0xc6:
+		// Binary data is treated like strings.
+		n, err := unpackUint(r, 1<<(c-0xc4))
+		if err != nil {
+			return nil, err
+		}
+
+		return unpackString(r, int(n))
+	case 0xdc, 0xdd:
+		n, err := unpackUint(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+
+		return unpackArray(r, int(n))
+	case 0xde, 0xdf:
+		n, err := unpackUint(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+
+		return unpackMap(r, int(n))
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x", c)
+}
+
+// unpackUint reads a big-endian unsigned integer of the given size (in bytes).
+func unpackUint(r *bytes.Reader, size int) (uint64, error) {
+	var n uint64
+	for i := 0; i < size; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		n = n<<8 | uint64(b)
+	}
+
+	return n, nil
+}
+
+func unpackString(r *bytes.Reader, n int) (string, error) {
+	if n > r.Len() {
+		return "", errors.New("msgpack: string exceeds input")
+	}
+
+	b := make([]byte, n)
+	r.Read(b)
+	return string(b), nil
+}
+
+func unpackArray(r *bytes.Reader, n int) ([]interface{}, error) {
+	// Each element takes at least one byte.
+	if n > r.Len() {
+		return nil, errors.New("msgpack: array exceeds input")
+	}
+
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := unpackValue(r)
+		if err != nil {
+			return nil, err
+		}
+
+		a[i] = v
+	}
+
+	return a, nil
+}
+
+func unpackMap(r *bytes.Reader, n int) (map[string]interface{}, error) {
+	if 2*n > r.Len() {
+		return nil, errors.New("msgpack: map exceeds input")
+	}
+
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := unpackValue(r)
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := k.(string)
+		if !ok {
+			return nil, errors.New("msgpack: map keys should be strings")
+		}
+
+		v, err := unpackValue(r)
+		if err != nil {
+			return nil, err
+		}
+
+		m[key] = v
+	}
+
+	return m, nil
+}
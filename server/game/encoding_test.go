@@ -0,0 +1,73 @@
+package game
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	turn := json.RawMessage(`{"card":{"label":"10","suite":"h"},"actionId":"a1","turn":3}`)
+	msg := &GameMessage{
+		Player: "player1",
+		Room:   "test",
+		Event:  eventPlayerTurn,
+		Response: &DealResponse{
+			Hand:          []Card{Card{"10", "h"}, Card{"A", "s"}},
+			Table:         []PlayerCard{PlayerCard{"player2", Card{"K", "h"}}},
+			OurTurn:       true,
+			OpponentHands: map[string]uint8{"player2": 12, "player3": 13},
+			Turn:          70000,
+		},
+		Data:      &turn,
+		Msg:       strings.Repeat("x", 300),
+		Seq:       1 << 40,
+		Timestamp: -12345,
+	}
+
+	data, _ := json.Marshal(msg)
+	packed, err := jsonToMsgpack(data)
+	assert.Nil(err)
+	assert.True(len(packed) < len(data))
+
+	unpacked, err := msgpackToJSON(packed)
+	assert.Nil(err)
+	assert.JSONEq(string(data), string(unpacked))
+
+	// Clients may send either encoding.
+	var fromJSON, fromMsgpack GameMessage
+	assert.Nil(decodeMessage(data, &fromJSON))
+	assert.Nil(decodeMessage(packed, &fromMsgpack))
+	assert.Equal(fromJSON.Seq, fromMsgpack.Seq)
+	assert.Equal(fromJSON.Timestamp, fromMsgpack.Timestamp)
+	assert.Equal(fromJSON.Msg, fromMsgpack.Msg)
+	assert.JSONEq(string(*fromJSON.Data), string(*fromMsgpack.Data))
+
+	// Frames ending with numbers which look like whitespace.
+	for _, seq := range []uint64{10, 32} {
+		data, _ = json.Marshal(&GameMessage{Event: eventReplay, Player: "player1", Room: "test", Seq: seq})
+		packed, _ = jsonToMsgpack(data)
+		assert.EqualValues(seq, packed[len(packed)-1])
+		var decoded GameMessage
+		assert.Nil(decodeMessage(packed, &decoded))
+		assert.Equal(seq, decoded.Seq)
+	}
+
+	var padded GameMessage
+	assert.Nil(decodeMessage([]byte(" \n{\"event\":\"Hello\"}\n"), &padded))
+	assert.Equal(eventHello, padded.Event)
+	assert.Equal(errMalformedFrame, decodeMessage([]byte(" "), &padded))
+
+	// Truncated and garbage input.
+	_, err = msgpackToJSON(packed[:len(packed)-1])
+	assert.NotNil(err)
+	_, err = msgpackToJSON([]byte{0xc1})
+	assert.NotNil(err)
+	_, err = msgpackToJSON([]byte{0x81, 0x01, 0x02})
+	assert.NotNil(err)
+	_, err = msgpackToJSON([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	assert.NotNil(err)
+}
@@ -0,0 +1,112 @@
+package game
+
+import "net/http"
+
+// errorCode is a stable identifier for the errors in `HandlerError`, so that
+// clients don't have to rely on messages.
+type errorCode string
+
+const (
+	// Room doesn't exist.
+	errRoomMissing errorCode = "RoomMissing"
+	// Room is full and nobody has left.
+	errRoomFull errorCode = "RoomFull"
+	// Player already exists with that name in the room.
+	errPlayerExists errorCode = "PlayerExists"
+	// Player doesn't belong in the room.
+	errNotInRoom errorCode = "NotInRoom"
+	// Request payload couldn't be decoded.
+	errInvalidRequest errorCode = "InvalidRequest"
+	// Number of players requested for the room isn't allowed.
+	errPlayerLimit errorCode = "PlayerLimit"
+	// Player has submitted a card when it's not their turn.
+	errNotYourTurn errorCode = "NotYourTurn"
+	// Player has submitted a card which isn't in their hand.
+	errCardMissing errorCode = "CardMissing"
+	// Player has started a round without being the dealer.
+	errNotDealer errorCode = "NotDealer"
+	// Player has broken the suite while having a card matching it.
+	errIllegalMove errorCode = "IllegalMove"
+	// Player has already requested a restart.
+	errNotAllowed errorCode = "NotAllowed"
+	// Event isn't allowed in the current phase of the room.
+	errInvalidPhase errorCode = "InvalidPhase"
+	// Client's version of the protocol isn't supported anymore.
+	errUpgradeRequired errorCode = "UpgradeRequired"
+	// Player has submitted a card for an earlier turn.
+	errStaleTurn errorCode = "StaleTurn"
+	// Client is sending some event too often.
+	errRateLimited errorCode = "RateLimited"
+	// Message from the client is larger than what's allowed.
+	errMessageTooLarge errorCode = "MessageTooLarge"
+	// Server has reached its limit for rooms or connections.
+	errServerFull errorCode = "ServerFull"
+	// Server has failed to handle the request.
+	errInternal errorCode = "Internal"
+)
+
+// CardDetails for errors involving some card. For `errCardMissing`, this is
+// the submitted card. For `errIllegalMove`, this is the card in the player's
+// hand which matches the suite in table.
+type CardDetails struct {
+	Card Card `json:"card"`
+}
+
+// PlayerLimitDetails for `errPlayerLimit`.
+type PlayerLimitDetails struct {
+	// Minimum number of players allowed in a room.
+	Min uint8 `json:"min"`
+	// Maximum number of players allowed in a room.
+	Max uint8 `json:"max"`
+}
+
+// RoomDetails for errors specific to some room.
+type RoomDetails struct {
+	// ID of the room.
+	Room string `json:"room"`
+}
+
+// PlayerDetails for errors specific to some player in a room.
+type PlayerDetails struct {
+	// ID of the room.
+	Room string `json:"room"`
+	// ID of the player.
+	Player string `json:"player"`
+}
+
+// httpStatus for this error in the REST API.
+func (c errorCode) httpStatus() int {
+	switch c {
+	case errRoomMissing:
+		return http.StatusNotFound
+	case errNotInRoom, errNotAllowed:
+		return http.StatusForbidden
+	case errInvalidRequest, errPlayerLimit, errCardMissing, errIllegalMove:
+		return http.StatusBadRequest
+	case errUpgradeRequired:
+		return http.StatusUpgradeRequired
+	case errRateLimited:
+		return http.StatusTooManyRequests
+	case errMessageTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case errServerFull:
+		return http.StatusServiceUnavailable
+	case errInternal:
+		return http.StatusInternalServerError
+	}
+
+	// Everything else conflicts with the state of the room.
+	return http.StatusConflict
+}
+
+// TurnDetails for `errStaleTurn`.
+type TurnDetails struct {
+	// Current turn in the room.
+	Turn uint64 `json:"turn"`
+}
+
+// PhaseDetails for `errInvalidPhase`.
+type PhaseDetails struct {
+	// Current phase of the room.
+	Phase roomPhase `json:"phase"`
+}
@@ -0,0 +1,76 @@
+package game
+
+import "time"
+
+const (
+	// Event for player requesting to join a room and for server
+	// notifying of a player joining some room.
+	eventPlayerJoin = "PlayerJoin"
+	// Event for player creating a new room.
+	eventRoomCreate = "RoomCreate"
+	// Room already exists and is full.
+	eventRoomExists = "RoomExists"
+	// Cannot find room for joining.
+	eventRoomMissing = "RoomMissing"
+	// Player already exists with that name in the room.
+	eventPlayerExists = "PlayerExists"
+	// Event for player submitting a card for their turn and for server
+	// notifying about a player's turn.
+	eventPlayerTurn = "PlayerTurn"
+	// One player has lost.
+	eventGameOver = "GameOver"
+	// Some player has successfully gotten rid of all cards in their hand.
+	eventPlayerWins = "PlayerWin"
+	// Player has sent a message.
+	eventPlayerMsg = "PlayerMsg"
+	// Player has asked to end the current game and go back to the lobby.
+	eventNewGameRequest = "GameRestartRequest"
+	// Server has ended the game and everyone has to get ready again.
+	eventGameRestart = "GameRestart"
+	// Event for player toggling their readiness and for server notifying
+	// of a player's readiness in some room.
+	eventPlayerReady = "PlayerReady"
+	// All players are ready and the server has begun (or cancelled)
+	// the countdown for dealing.
+	eventGameCountdown = "GameCountdown"
+	// Player has sent an event which isn't allowed in the current phase of the room.
+	eventInvalidPhase = "InvalidPhase"
+	// All cards for some round are in the table (or someone has broken the suite).
+	eventTrickResolved = "TrickResolved"
+	// Event for client initiating the handshake and for server replying
+	// with its version and features.
+	eventHello = "Hello"
+	// Client's version of the protocol isn't supported by the server.
+	eventUpgradeRequired = "UpgradeRequired"
+	// Event for player requesting missed messages and for server
+	// notifying before replaying them.
+	eventReplay = "Replay"
+	// Server has accepted some request from the player.
+	eventAck = "Ack"
+	// Changes in the game since the previous deal (for clients which have enabled deltas).
+	eventPlayerTurnDelta = "PlayerTurnDelta"
+	// Client has missed some changes in the game and needs the whole view.
+	eventResync = "Resync"
+	// Client is sending some event too often.
+	eventRateLimited = "RateLimited"
+	// Server can't take more rooms or connections right now.
+	eventServerFull = "ServerFull"
+)
+
+const (
+	// Default limits for players in a room.
+	minPlayers = 3
+	maxPlayers = 6
+	// Bounds for configuring the player limits (so that everyone gets a few cards).
+	lowestPlayerLimit  = 2
+	highestPlayerLimit = 13
+	// Default timeout for removing rooms after everyone has left.
+	roomDeletionTimeout = 5 * time.Minute
+	// Default interval for cleaning up rooms and sessions.
+	cleanupInterval = 30 * time.Second
+
+	// Default for the max size of messages from clients.
+	maxMessageBytes = 64 * 1024
+
+	gameCountdownSeconds = 3
+)
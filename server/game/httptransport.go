@@ -0,0 +1,313 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Max number of messages queued for a client which isn't listening.
+	httpQueueSize = 256
+	// How long a long-poll request waits for messages before returning.
+	httpPollTimeout = 25 * time.Second
+	// Default for how long a session lives without the client polling or streaming.
+	httpSessionTimeout = time.Minute
+)
+
+// httpConnection queues messages for a client which receives them over
+// server-sent events or long-polling (and sends its own messages via POST).
+type httpConnection struct {
+	lock sync.Mutex
+	// Lock so that messages from the client are handled one at a time.
+	handling sync.Mutex
+	// Settings of this connection.
+	sess *session
+	// Messages which haven't been delivered to the client yet.
+	queue [][]byte
+	// Signalled whenever some message is queued.
+	notify chan struct{}
+	// Whether some request is currently waiting for messages.
+	listening bool
+	// Last time the client was around.
+	lastSeen time.Time
+}
+
+func (c *httpConnection) send(data []byte) error {
+	c.lock.Lock()
+	c.queue = append(c.queue, data)
+	if len(c.queue) > httpQueueSize {
+		// Clients which have fallen behind can replay the missed messages.
+		c.queue = c.queue[len(c.queue)-httpQueueSize:]
+	}
+	c.lock.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// take all queued messages.
+func (c *httpConnection) take() [][]byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	messages := c.queue
+	c.queue = nil
+	c.lastSeen = time.Now()
+	return messages
+}
+
+// playerID using this connection (if any).
+func (c *httpConnection) playerID() string {
+	c.handling.Lock()
+	defer c.handling.Unlock()
+	return c.sess.playerID
+}
+
+// setListening marks whether some request is waiting for messages.
+func (c *httpConnection) setListening(listening bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.listening = listening
+	c.lastSeen = time.Now()
+}
+
+// httpTransport serves the game for clients which can't use websockets.
+//
+// Clients begin with `POST connect` to get a session, then receive messages
+// from `GET events` (server-sent events) or `GET poll` (long-polling) and
+// send their messages to `POST send`. All of these take the session in
+// the `session` query parameter.
+type httpTransport struct {
+	hub  *Hub
+	lock sync.Mutex
+	// Map of session IDs to connections.
+	sessions map[string]*httpConnection
+	// Ticker for expiring sessions of clients which have gone away.
+	ticker *time.Ticker
+}
+
+func newHTTPTransport(hub *Hub, interval time.Duration) *httpTransport {
+	return &httpTransport{
+		hub:      hub,
+		sessions: make(map[string]*httpConnection),
+		ticker:   time.NewTicker(interval),
+	}
+}
+
+// watchSessions and drop the ones which have been idle for too long.
+//
+// **NOTE:** This must be launched into a separate goroutine.
+func (t *httpTransport) watchSessions() {
+	for {
+		select {
+		case <-t.hub.done:
+			return
+		case <-t.ticker.C:
+		}
+
+		timeout := t.hub.settings().SessionTimeout
+		expired := make([]*httpConnection, 0)
+		t.lock.Lock()
+		for id, c := range t.sessions {
+			c.lock.Lock()
+			idle := !c.listening && time.Since(c.lastSeen) > timeout
+			c.lock.Unlock()
+			if idle {
+				t.hub.opts.Logger.Printf("Expiring HTTP session %s\n", id)
+				delete(t.sessions, id)
+				expired = append(expired, c)
+			}
+		}
+		t.lock.Unlock()
+
+		for _, c := range expired {
+			t.hub.removeConnection(c.sess.addr)
+			t.hub.dropPlayer(c, c.playerID())
+		}
+	}
+}
+
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if action == "connect" && r.Method == http.MethodPost {
+		t.connect(w, r)
+		return
+	}
+
+	c := t.session(r.URL.Query().Get("session"))
+	if c == nil {
+		http.Error(w, "Unknown session.", http.StatusNotFound)
+		return
+	}
+
+	if action == "events" && r.Method == http.MethodGet {
+		t.stream(w, r, c)
+	} else if action == "poll" && r.Method == http.MethodGet {
+		t.poll(w, r, c)
+	} else if action == "send" && r.Method == http.MethodPost {
+		t.receive(w, r, c)
+	} else {
+		http.Error(w, "Not found.", http.StatusNotFound)
+	}
+}
+
+// session for the given ID (if it exists).
+func (t *httpTransport) session(id string) *httpConnection {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.sessions[id]
+}
+
+// connect creates a new session for the client.
+func (t *httpTransport) connect(w http.ResponseWriter, r *http.Request) {
+	id, err := newToken()
+	if err != nil {
+		http.Error(w, "Unable to create session.", http.StatusInternalServerError)
+		return
+	}
+
+	addr := t.hub.clientAddr(r)
+	if e := t.hub.addConnection(addr); e != nil {
+		e.localize(preferredLanguage(r.Header.Get("Accept-Language")))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.Code.httpStatus())
+		json.NewEncoder(w).Encode(&APIErrorResponse{Error: e})
+		return
+	}
+
+	c := &httpConnection{
+		sess: &session{
+			addr:     addr,
+			lang:     preferredLanguage(r.Header.Get("Accept-Language")),
+			encoding: encodingJSON,
+		},
+		notify:   make(chan struct{}, 1),
+		lastSeen: time.Now(),
+	}
+
+	t.lock.Lock()
+	t.sessions[id] = c
+	t.lock.Unlock()
+	t.hub.opts.Logger.Printf("New HTTP session %s from %s\n", id, c.sess.addr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"session": id})
+}
+
+// close the session and drop the player.
+func (t *httpTransport) close(id string, c *httpConnection) {
+	t.lock.Lock()
+	_, exists := t.sessions[id]
+	delete(t.sessions, id)
+	t.lock.Unlock()
+	if exists {
+		t.hub.removeConnection(c.sess.addr)
+	}
+
+	t.hub.dropPlayer(c, c.playerID())
+}
+
+// stream messages to the client as server-sent events.
+func (t *httpTransport) stream(w http.ResponseWriter, r *http.Request, c *httpConnection) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming isn't supported.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	c.setListening(true)
+	defer c.setListening(false)
+	for {
+		for _, m := range c.take() {
+			fmt.Fprintf(w, "data: %s\n\n", m)
+		}
+		flusher.Flush()
+
+		select {
+		case <-c.notify:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// poll for messages, waiting for a while if there aren't any.
+func (t *httpTransport) poll(w http.ResponseWriter, r *http.Request, c *httpConnection) {
+	c.setListening(true)
+	defer c.setListening(false)
+
+	messages := c.take()
+	if len(messages) == 0 {
+		select {
+		case <-c.notify:
+			messages = c.take()
+		case <-time.After(httpPollTimeout):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	raw := make([]json.RawMessage, 0, len(messages))
+	for _, m := range messages {
+		raw = append(raw, json.RawMessage(m))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(raw)
+}
+
+// receive a message from the client and handle it like any other connection.
+func (t *httpTransport) receive(w http.ResponseWriter, r *http.Request, c *httpConnection) {
+	var msg GameMessage
+	frame, err := readFrame(r.Body, t.hub.opts.MaxMessageBytes)
+	if err == nil {
+		err = decodeMessage(frame, &msg)
+	}
+
+	if err == errFrameTooLarge || err == errMalformedFrame {
+		// Errors are delivered along with other messages from the server.
+		c.handling.Lock()
+		ok := t.hub.rejectFrame(c, c.sess, err)
+		c.handling.Unlock()
+		if !ok {
+			t.close(r.URL.Query().Get("session"), c)
+		}
+
+		status := http.StatusBadRequest
+		if err == errFrameTooLarge {
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		http.Error(w, "Invalid message.", status)
+		return
+	} else if err != nil {
+		http.Error(w, "Invalid message.", http.StatusBadRequest)
+		return
+	}
+
+	c.lock.Lock()
+	c.lastSeen = time.Now()
+	c.lock.Unlock()
+
+	// Replies are delivered along with other messages from the server.
+	c.handling.Lock()
+	ok := t.hub.handle(c, c.sess, &msg)
+	c.handling.Unlock()
+	if !ok {
+		t.close(r.URL.Query().Get("session"), c)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour})
+	defer hub.Close()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/http/connect", "application/json", nil)
+	assert.Nil(err)
+	var created map[string]string
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	id := created["session"]
+	assert.Len(id, 32)
+
+	send := func(body string) int {
+		resp, err := http.Post(server.URL+"/http/send?session="+id, "application/json", strings.NewReader(body))
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	poll := func() []GameMessage {
+		resp, err := http.Get(server.URL + "/http/poll?session=" + id)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		var messages []GameMessage
+		assert.Nil(json.NewDecoder(resp.Body).Decode(&messages))
+		return messages
+	}
+
+	assert.Equal(http.StatusAccepted, send(`{"event":"Hello","requestId":"1","data":{"version":2,"encodings":["msgpack"]}}`))
+	messages := poll()
+	assert.Len(messages, 1)
+	assert.Equal(eventHello, messages[0].Event)
+	assert.Equal("1", messages[0].RequestID)
+
+	// Messages from the server are queued until the client polls.
+	assert.Equal(http.StatusAccepted, send(`{"event":"RoomCreate","player":"alice","room":"attic","data":{"players":3}}`))
+	assert.Equal(http.StatusAccepted, send(`{"event":"PlayerTurn","player":"alice","room":"attic","data":{"card":{"label":"A","suite":"s"}}}`))
+	messages = poll()
+	assert.Equal(eventPlayerJoin, messages[0].Event)
+	assert.Equal(eventInvalidPhase, messages[len(messages)-1].Event)
+
+	room, exists := hub.getRoom("attic")
+	assert.True(exists)
+	room.lock.Lock()
+	assert.Contains(room.players, "alice")
+	room.lock.Unlock()
+
+	assert.Equal(http.StatusBadRequest, send(`{"event":`))
+	assert.Equal(http.StatusRequestEntityTooLarge, send(`{"event":"PlayerMsg","msg":"`+strings.Repeat("a", maxMessageBytes)+`"}`))
+	messages = poll()
+	assert.Len(messages, 2)
+	assert.Equal(errInvalidRequest, messages[0].Error.Code)
+	assert.Equal(errMessageTooLarge, messages[1].Error.Code)
+
+	resp, _ = http.Get(server.URL + "/http/poll?session=unknown")
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
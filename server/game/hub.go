@@ -0,0 +1,893 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Options for creating a hub. Zero values are replaced by defaults.
+type Options struct {
+	// Logger for the hub and its rooms (defaults to stderr).
+	Logger *log.Logger
+	// Store for the rooms (defaults to memory).
+	Store Store
+	// Limits for the number of players in a room.
+	MinPlayers uint8
+	MaxPlayers uint8
+	// How long rooms are kept around after everyone has left.
+	RoomTimeout time.Duration
+	// Interval for cleaning up rooms and sessions.
+	CleanupInterval time.Duration
+	// How long HTTP sessions live without the client polling or streaming.
+	SessionTimeout time.Duration
+	// Optional features (`acks`, `deltas`), encodings (`msgpack`) and transports (`http`, `api`)
+	// which shouldn't be offered by this hub.
+	Disable []string
+	// Whether the hub is behind a reverse proxy whose `X-Forwarded-For`
+	// and `X-Forwarded-Proto` headers can be trusted.
+	TrustProxy bool
+	// Origins (like `https://example.com`) allowed to use the websocket and
+	// the HTTP endpoints from browsers, or `*` for allowing everyone. Only
+	// the hub's own origin is allowed if this is empty.
+	AllowedOrigins []string
+	// Limits for events (by name, or `*` for the rest) from each connection
+	// and from each client address. Defaults are used if these are nil.
+	ConnRateLimits map[string]RateLimit
+	AddrRateLimits map[string]RateLimit
+	// Number of limited events after which a connection is dropped.
+	MaxRateViolations int
+	// Max size of messages from clients (in bytes).
+	MaxMessageBytes int
+	// Limits for rooms in the server, rooms created from each client address
+	// and connections from each client address.
+	MaxRooms        int
+	MaxRoomsPerAddr int
+	MaxConnsPerAddr int
+	// Number of players who can wait for creating rooms when the server
+	// is full (no queue if this is zero).
+	RoomQueue int
+	// Handler for requests which don't belong to the game (e.g., static files).
+	Fallback http.Handler
+}
+
+// Validate the options. Zero values are fine, since they're replaced by defaults.
+func (opts *Options) Validate() error {
+	min, max := opts.MinPlayers, opts.MaxPlayers
+	if min == 0 {
+		min = minPlayers
+	}
+
+	if max == 0 {
+		max = maxPlayers
+	}
+
+	if min < lowestPlayerLimit || max > highestPlayerLimit || min > max {
+		return fmt.Errorf("player limits should be within %d and %d (got %d and %d)",
+			lowestPlayerLimit, highestPlayerLimit, min, max)
+	}
+
+	if opts.RoomTimeout < 0 || opts.CleanupInterval < 0 || opts.SessionTimeout < 0 {
+		return fmt.Errorf("timeouts and intervals can't be negative")
+	}
+
+	if err := validRateLimits(opts.ConnRateLimits); err != nil {
+		return err
+	}
+
+	if err := validRateLimits(opts.AddrRateLimits); err != nil {
+		return err
+	}
+
+	if opts.MaxRateViolations < 0 {
+		return fmt.Errorf("max rate violations can't be negative")
+	}
+
+	if opts.MaxMessageBytes < 0 {
+		return fmt.Errorf("max message size can't be negative")
+	}
+
+	if opts.MaxRooms < 0 || opts.MaxRoomsPerAddr < 0 || opts.MaxConnsPerAddr < 0 || opts.RoomQueue < 0 {
+		return fmt.Errorf("limits for rooms and connections can't be negative")
+	}
+
+	for _, o := range opts.AllowedOrigins {
+		if err := validOrigin(o); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range opts.Disable {
+		if !hasFeature(Toggles(), name) {
+			return fmt.Errorf("unknown feature %q (should be one of %s)",
+				name, strings.Join(Toggles(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// setDefaults for the settings which haven't been set.
+func (opts *Options) setDefaults() {
+	if opts.MinPlayers == 0 {
+		opts.MinPlayers = minPlayers
+	}
+
+	if opts.MaxPlayers == 0 {
+		opts.MaxPlayers = maxPlayers
+	}
+
+	if opts.RoomTimeout == 0 {
+		opts.RoomTimeout = roomDeletionTimeout
+	}
+
+	if opts.CleanupInterval == 0 {
+		opts.CleanupInterval = cleanupInterval
+	}
+
+	if opts.SessionTimeout == 0 {
+		opts.SessionTimeout = httpSessionTimeout
+	}
+
+	if opts.ConnRateLimits == nil {
+		opts.ConnRateLimits = connRateLimits
+	}
+
+	if opts.AddrRateLimits == nil {
+		opts.AddrRateLimits = addrRateLimits
+	}
+
+	if opts.MaxRateViolations == 0 {
+		opts.MaxRateViolations = maxRateViolations
+	}
+
+	if opts.MaxMessageBytes == 0 {
+		opts.MaxMessageBytes = maxMessageBytes
+	}
+
+	if opts.MaxRooms == 0 {
+		opts.MaxRooms = maxRooms
+	}
+
+	if opts.MaxRoomsPerAddr == 0 {
+		opts.MaxRoomsPerAddr = maxRoomsPerAddr
+	}
+
+	if opts.MaxConnsPerAddr == 0 {
+		opts.MaxConnsPerAddr = maxConnsPerAddr
+	}
+}
+
+// DefaultOptions returns the settings used by hubs for the options which
+// haven't been set.
+func DefaultOptions() Options {
+	var opts Options
+	opts.setDefaults()
+	// Callers shouldn't be able to change the defaults for everyone else.
+	opts.ConnRateLimits = copyRateLimits(opts.ConnRateLimits)
+	opts.AddrRateLimits = copyRateLimits(opts.AddrRateLimits)
+	return opts
+}
+
+// Toggles returns the names of everything which can be disabled in a hub.
+func Toggles() []string {
+	names := append([]string{}, serverFeatures...)
+	return append(names, string(encodingMsgpack), "http", "api")
+}
+
+// Hub contains a map of websocket connections and the associated player IDs.
+//
+// Hubs serve the game over websockets (`/ws`), over HTTP for networks which
+// block websockets (`/http/`) and through the REST API (`/api/`). Use
+// `http.StripPrefix` for mounting them elsewhere.
+type Hub struct {
+	// Settings of this hub.
+	opts Options
+	// Lock for the settings which can be changed at runtime.
+	optsLock sync.RWMutex
+	// Router for the hub's endpoints.
+	mux *http.ServeMux
+	// Transport for clients which can't use websockets.
+	transport *httpTransport
+	// REST API for playing over plain HTTP.
+	api *apiHandler
+	// Optional features offered to clients.
+	features []string
+	// Encodings offered to clients (in the order of our preference).
+	encodings []wireEncoding
+	// Map of room IDs to actual room objects.
+	store Store
+	// Map of connections (over any transport) to room IDs.
+	connRooms map[connection]string
+	// Hub command channel.
+	cmdChan chan hubCommand
+	// Channel for room pointers from hub.
+	roomChan chan *Room
+	// Channel for IDs from hub.
+	connChan chan string
+	// Ack channel for other operations.
+	ackChan chan bool
+	// Rooms and connections for enforcing the limits.
+	capacity *capacity
+	// Rate limiters for client addresses.
+	addrLimiters map[string]*rateLimiter
+	limitersLock sync.Mutex
+	// Ticker for this hub to perform cleanups over some interval.
+	ticker *time.Ticker
+	// Closed when the hub is closed (for stopping its goroutines).
+	done      chan struct{}
+	closeOnce sync.Once
+	// Goroutines watching for events and cleanups in this hub.
+	watchers sync.WaitGroup
+}
+
+// NewHub creates a hub with the given options and starts watching for events.
+func NewHub(opts Options) *Hub {
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+
+	opts.setDefaults()
+	hub := &Hub{
+		opts:         opts,
+		store:        opts.Store,
+		connRooms:    make(map[connection]string),
+		capacity:     newCapacity(len(opts.Store.IDs())),
+		addrLimiters: make(map[string]*rateLimiter),
+		cmdChan:      make(chan hubCommand),
+		roomChan:     make(chan *Room),
+		connChan:     make(chan string),
+		ackChan:      make(chan bool),
+		ticker:       time.NewTicker(opts.CleanupInterval),
+		done:         make(chan struct{}),
+	}
+
+	for _, f := range serverFeatures {
+		if !hasFeature(opts.Disable, f) {
+			hub.features = append(hub.features, f)
+		}
+	}
+
+	for _, e := range serverEncodings {
+		if !hasFeature(opts.Disable, string(e)) {
+			hub.encodings = append(hub.encodings, e)
+		}
+	}
+
+	hub.transport = newHTTPTransport(hub, opts.CleanupInterval)
+	hub.api = newAPIHandler(hub, opts.CleanupInterval)
+	hub.mux = http.NewServeMux()
+	hub.mux.Handle("/ws", websocket.Server{
+		Handler:   hub.serve,
+		Handshake: hub.checkWebsocketOrigin,
+	})
+	if !hasFeature(opts.Disable, "http") {
+		hub.mux.Handle("/http/", hub.withCORS(hub.transport))
+	}
+
+	if !hasFeature(opts.Disable, "api") {
+		hub.mux.Handle("/api/", hub.withCORS(http.StripPrefix("/api", hub.api)))
+	}
+
+	if opts.Fallback != nil {
+		hub.mux.Handle("/", opts.Fallback)
+	}
+
+	hub.watch(hub.watchEvents)
+	hub.watch(hub.transport.watchSessions)
+	hub.watch(hub.api.watchSeats)
+	return hub
+}
+
+// watch launches the given watcher into a separate goroutine, which
+// is expected to return once this hub has been closed.
+func (hub *Hub) watch(watcher func()) {
+	hub.watchers.Add(1)
+	go func() {
+		defer hub.watchers.Done()
+		watcher()
+	}()
+}
+
+// Close the hub by stopping its cleanups and goroutines. The hub shouldn't
+// be used after it's been closed.
+func (hub *Hub) Close() {
+	hub.closeOnce.Do(func() {
+		hub.ticker.Stop()
+		hub.transport.ticker.Stop()
+		hub.api.ticker.Stop()
+		close(hub.done)
+	})
+}
+
+// Reload the settings which are safe to change at runtime. Player limits
+// apply to new rooms, while timeouts, origins, rate limits and capacity
+// apply to the existing rooms and connections as well. Other settings only take effect
+// in a new hub.
+func (hub *Hub) Reload(opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	opts.setDefaults()
+	hub.optsLock.Lock()
+	hub.opts.MinPlayers, hub.opts.MaxPlayers = opts.MinPlayers, opts.MaxPlayers
+	hub.opts.RoomTimeout = opts.RoomTimeout
+	hub.opts.SessionTimeout = opts.SessionTimeout
+	hub.opts.AllowedOrigins = opts.AllowedOrigins
+	hub.opts.ConnRateLimits, hub.opts.AddrRateLimits = opts.ConnRateLimits, opts.AddrRateLimits
+	hub.opts.MaxRateViolations = opts.MaxRateViolations
+	hub.opts.MaxRooms, hub.opts.MaxRoomsPerAddr = opts.MaxRooms, opts.MaxRoomsPerAddr
+	hub.opts.MaxConnsPerAddr, hub.opts.RoomQueue = opts.MaxConnsPerAddr, opts.RoomQueue
+	hub.optsLock.Unlock()
+
+	// Raising the limit for rooms should let in the players waiting for it.
+	hub.admitNext()
+	return nil
+}
+
+// settings returns the current settings of this hub.
+func (hub *Hub) settings() Options {
+	hub.optsLock.RLock()
+	defer hub.optsLock.RUnlock()
+	return hub.opts
+}
+
+// ServeHTTP routes the request to the websocket, HTTP transport or the API.
+func (hub *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hub.mux.ServeHTTP(w, r)
+}
+
+type hubCmdType int
+
+const (
+	cmdSetRoom = iota
+	cmdGetRoom
+	cmdAddRoom
+	cmdSetConnection
+	cmdDeleteConnection
+)
+
+// Command sent for requesting/updating stuff in the hub.
+type hubCommand struct {
+	// Type of the command (mandatory).
+	ty     hubCmdType
+	roomID string
+	room   *Room
+	conn   connection
+}
+
+/* Map-like methods specific to our types. */
+
+// getRoom corresponding to the given room ID.
+func (hub *Hub) getRoom(roomID string) (*Room, bool) {
+	hub.cmdChan <- hubCommand{
+		ty:     cmdGetRoom,
+		roomID: roomID,
+	}
+
+	room := <-hub.roomChan
+	return room, room != nil
+}
+
+// setRoom for the given ID.
+func (hub *Hub) setRoom(roomID string, room *Room) {
+	hub.cmdChan <- hubCommand{
+		ty:     cmdSetRoom,
+		roomID: roomID,
+		room:   room,
+	}
+	_ = <-hub.ackChan
+}
+
+// addRoom for the given ID unless there's a room with that ID already.
+// Returns the room which has that ID afterwards.
+func (hub *Hub) addRoom(roomID string, room *Room) *Room {
+	hub.cmdChan <- hubCommand{
+		ty:     cmdAddRoom,
+		roomID: roomID,
+		room:   room,
+	}
+
+	return <-hub.roomChan
+}
+
+// setConnection to the given room ID.
+func (hub *Hub) setConnection(conn connection, roomID string) {
+	hub.cmdChan <- hubCommand{
+		ty:     cmdSetConnection,
+		roomID: roomID,
+		conn:   conn,
+	}
+	_ = <-hub.ackChan
+}
+
+// deleteConnection and return its room ID (if any).
+func (hub *Hub) deleteConnection(conn connection) (string, bool) {
+	hub.cmdChan <- hubCommand{
+		ty:   cmdDeleteConnection,
+		conn: conn,
+	}
+	id := <-hub.connChan
+	return id, id != ""
+}
+
+// watchEvents for handling commands and performing cleanup in this hub.
+//
+// **NOTE:** This must be launched into a separate goroutine.
+func (hub *Hub) watchEvents() {
+	for {
+		select {
+		case <-hub.done:
+			return
+		case <-hub.ticker.C:
+			currentTime := time.Now()
+			timeout := hub.settings().RoomTimeout
+			hub.pruneLimiters(currentTime)
+			for _, id := range hub.store.IDs() {
+				room := hub.store.Get(id)
+				room.lock.Lock()
+				allLeft := true
+				for _, p := range room.players {
+					allLeft = allLeft && p.left
+				}
+
+				if !allLeft {
+					room.lock.Unlock()
+					continue
+				}
+
+				diff := currentTime.Sub(room.lastUpdatedTime)
+				if diff < timeout {
+					room.lock.Unlock()
+					continue
+				}
+
+				hub.opts.Logger.Printf("Removing room %s after timeout.\n", id)
+				hub.store.Delete(id)
+				hub.releaseRoom(room)
+			}
+		case cmd := <-hub.cmdChan:
+			if cmd.ty == cmdGetRoom {
+				room := hub.store.Get(cmd.roomID)
+				hub.roomChan <- room
+			} else if cmd.ty == cmdAddRoom {
+				room := hub.store.Get(cmd.roomID)
+				if room == nil {
+					room = cmd.room
+					hub.store.Set(cmd.roomID, room)
+				}
+
+				hub.roomChan <- room
+			} else if cmd.ty == cmdSetRoom {
+				hub.store.Set(cmd.roomID, cmd.room)
+				hub.ackChan <- true
+			} else if cmd.ty == cmdSetConnection {
+				hub.connRooms[cmd.conn] = cmd.roomID
+				hub.ackChan <- true
+			} else if cmd.ty == cmdDeleteConnection {
+				roomID, exists := hub.connRooms[cmd.conn]
+				if !exists {
+					roomID = ""
+				}
+
+				delete(hub.connRooms, cmd.conn)
+				hub.connChan <- roomID
+			}
+		}
+	}
+}
+
+// session holds the settings of some connection.
+type session struct {
+	// ID of the player using this connection (set after their first event).
+	playerID string
+	// Address of the client.
+	addr string
+	// Language in which the player wants messages from the server.
+	lang string
+	// Whether the transport can carry binary messages.
+	binary bool
+	// Rate limiter for the events from this connection.
+	limiter *rateLimiter
+	// Lock for the language and the settings negotiated in the handshake
+	// (since messages are sent to this connection from other goroutines).
+	lock sync.RWMutex
+	// Encoding for messages sent to this connection (set after the handshake).
+	encoding wireEncoding
+	// Features enabled for this connection (set after the handshake).
+	features []string
+}
+
+// negotiated returns the encoding and features of this connection.
+func (s *session) negotiated() (wireEncoding, []string) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.encoding, s.features
+}
+
+// language in which the player currently wants messages from the server.
+func (s *session) language() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.lang
+}
+
+// setLanguage changes the language of the messages sent to this connection.
+func (s *session) setLanguage(lang string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lang = lang
+}
+
+// negotiate the encoding and features of this connection.
+func (s *session) negotiate(enc wireEncoding, features []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.encoding, s.features = enc, features
+}
+
+// Serve an incoming websocket connection.
+func (hub *Hub) serve(ws *websocket.Conn) {
+	sess := &session{
+		addr:     hub.clientAddr(ws.Request()),
+		lang:     preferredLanguage(ws.Request().Header.Get("Accept-Language")),
+		encoding: encodingJSON,
+		binary:   true,
+	}
+
+	hub.opts.Logger.Printf("New websocket connection from %s\n", sess.addr)
+	if e := hub.addConnection(sess.addr); e != nil {
+		hub.sendError(&wsConnection{ws: ws, sess: sess}, "", sess, "", e)
+		return
+	}
+
+	defer hub.removeConnection(sess.addr)
+
+	ws.MaxPayloadBytes = hub.opts.MaxMessageBytes
+	conn := &wsConnection{ws: ws, sess: sess}
+	for {
+		var msg GameMessage
+		err := receiveMessage(ws, &msg)
+		if (err == errFrameTooLarge || err == errMalformedFrame) && hub.rejectFrame(conn, sess, err) {
+			continue
+		}
+
+		if err != nil {
+			hub.dropPlayer(conn, sess.playerID)
+			break
+		}
+
+		if !hub.handle(conn, sess, &msg) {
+			hub.dropPlayer(conn, sess.playerID)
+			break
+		}
+	}
+}
+
+// handle an incoming message from the given connection (over any transport).
+// Returns `false` if the connection should be closed.
+func (hub *Hub) handle(conn connection, sess *session, msg *GameMessage) bool {
+	if l := supportedLanguage(msg.Locale); l != "" {
+		sess.setLanguage(l)
+	}
+
+	responseErr, ok := hub.limitRate(sess, msg.Event)
+	if responseErr == nil && ok {
+		responseErr = validateMessage(msg)
+	}
+
+	if responseErr != nil || !ok {
+		hub.sendError(conn, strings.TrimSpace(msg.Room), sess, msg.RequestID, responseErr)
+		return ok
+	}
+
+	if msg.Event == eventHello {
+		var resp *HelloResponse
+		if resp, responseErr = hub.greet(conn, sess, msg.RequestID, msg.Data); resp != nil {
+			sess.negotiate(resp.Encoding, resp.Enabled)
+		}
+	} else if _, features := sess.negotiated(); features == nil {
+		// Clients should begin with a handshake. If they don't, then
+		// they're probably using an older version of the protocol.
+		responseErr = hub.upgradeRequired()
+	}
+
+	if msg.Event == eventHello || responseErr != nil {
+		hub.sendError(conn, "", sess, msg.RequestID, responseErr)
+		return responseErr == nil || responseErr.Code != errUpgradeRequired
+	}
+
+	// All events other than the handshake have a player (as per their schema).
+	playerID := strings.ToLower(strings.TrimSpace(msg.Player))
+	sess.playerID = playerID
+	roomID := strings.TrimSpace(msg.Room)
+	hub.opts.Logger.Printf("Event %s from player %s (%s) for room %s\n", msg.Event, playerID, sess.addr, roomID)
+
+	if msg.Event != eventRoomCreate && msg.Event != eventPlayerJoin {
+		// Players have to join before acting for some seat.
+		if responseErr = hub.checkSeat(conn, roomID, playerID); responseErr != nil {
+			hub.sendError(conn, roomID, sess, msg.RequestID, responseErr)
+			return true
+		}
+	}
+
+	if msg.Event == eventRoomCreate {
+		responseErr = hub.createRoomWithPlayer(conn, roomID, playerID, sess, msg.Data)
+	} else if msg.Event == eventPlayerJoin {
+		responseErr = hub.addPlayer(conn, roomID, playerID, sess)
+	} else if msg.Event == eventPlayerTurn {
+		responseErr = hub.validateAndApplyTurn(conn, roomID, playerID, msg.Data)
+	} else if msg.Event == eventPlayerMsg {
+		responseErr = hub.shareMessage(conn, roomID, playerID, msg.Msg)
+	} else if msg.Event == eventNewGameRequest {
+		responseErr = hub.playerRequestedNewGame(conn, roomID, playerID)
+	} else if msg.Event == eventPlayerReady {
+		responseErr = hub.setPlayerReady(conn, roomID, playerID, msg.Data)
+	} else if msg.Event == eventReplay {
+		responseErr = hub.replayMessages(conn, roomID, playerID, msg.RequestID, msg.Data)
+	} else if msg.Event == eventResync {
+		responseErr = hub.resyncPlayer(conn, roomID, playerID)
+	}
+
+	if _, features := sess.negotiated(); responseErr == nil && hasFeature(features, "acks") {
+		hub.sendAck(conn, roomID, sess, msg)
+	}
+
+	hub.sendError(conn, roomID, sess, msg.RequestID, responseErr)
+	return true
+}
+
+// checkSeat ensures that the player (if they're in the given room) is using
+// the given connection, so that nobody can act for someone else's seat.
+func (hub *Hub) checkSeat(conn connection, roomID, playerID string) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return nil
+	}
+
+	room.lock.Lock()
+	player, exists := room.players[playerID]
+	owned := !exists || player.conn == conn
+	room.lock.Unlock()
+	if owned {
+		return nil
+	}
+
+	return &HandlerError{
+		Code:    errNotInRoom,
+		key:     msgNotInRoom,
+		args:    []interface{}{roomID},
+		Details: &RoomDetails{Room: roomID},
+	}
+}
+
+// sendError (if any) to the given connection in the given language.
+func (hub *Hub) sendError(conn connection, roomID string, sess *session, requestID string, responseErr *HandlerError) {
+	if responseErr == nil {
+		return
+	}
+
+	responseErr.localize(sess.language())
+	sendMessage(conn, &GameMessage{
+		Event:     responseErr.Event,
+		Msg:       responseErr.Msg,
+		Phase:     hub.roomPhase(roomID),
+		Error:     responseErr,
+		RequestID: requestID,
+	})
+}
+
+// sendAck to the given connection for the request which has been accepted.
+func (hub *Hub) sendAck(conn connection, roomID string, sess *session, msg *GameMessage) {
+	sendMessage(conn, &GameMessage{
+		Player:    msg.Player,
+		Room:      roomID,
+		Event:     eventAck,
+		Phase:     hub.roomPhase(roomID),
+		Response:  &AckResponse{Event: msg.Event},
+		RequestID: msg.RequestID,
+	})
+}
+
+// roomPhase returns the current phase of the given room (if it exists).
+func (hub *Hub) roomPhase(roomID string) roomPhase {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return ""
+	}
+
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	return room.phase
+}
+
+// Cleanup and drop a connection.
+func (hub *Hub) dropPlayer(conn connection, playerID string) {
+	hub.opts.Logger.Printf("Dropping connection for player %s\n", playerID)
+	hub.unqueue(conn)
+	roomID, exists := hub.deleteConnection(conn)
+	if !exists {
+		return
+	}
+
+	// Room may have been cleaned up already.
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return
+	}
+
+	room.lock.Lock()
+	defer room.lock.Unlock()
+
+	// Someone else may have taken the seat with another connection.
+	player := room.players[playerID]
+	if player == nil || player.conn != conn {
+		return
+	}
+
+	hub.opts.Logger.Printf("Disabling player %s in room %s\n", playerID, roomID)
+	player.left = true
+	// A player who isn't around can't be ready for the next game.
+	player.ready = false
+	room.updateCountdown()
+	room.broadcastNotice(msgPlayerLeft, playerID)
+
+	allLeft := true
+	for _, p := range room.players {
+		allLeft = allLeft && p.left
+	}
+
+	if allLeft {
+		hub.opts.Logger.Printf("All players have left the room %s\n", roomID)
+	}
+}
+
+// Models
+
+// HandlerError is the interface for all server reactions.
+type HandlerError struct {
+	Event string `json:"event"`
+	// Stable code identifying this error.
+	Code errorCode `json:"code"`
+	Msg  string    `json:"msg"`
+	// Machine-readable details specific to the error code (if any).
+	Details interface{} `json:"details,omitempty"`
+	// Key and arguments for rendering the message in the player's language.
+	key  msgKey
+	args []interface{}
+}
+
+// GameMessage represents a message through the websocket.
+type GameMessage struct {
+	Player   string           `json:"player"`
+	Room     string           `json:"room"`
+	Event    string           `json:"event"`
+	Data     *json.RawMessage `json:"data"`
+	Response interface{}      `json:"response"`
+	Msg      string           `json:"msg"`
+	// Language in which the player wants messages from the server (only set by clients).
+	Locale string `json:"locale,omitempty"`
+	// Phase of the room when this message was sent (only set by the server).
+	Phase roomPhase `json:"phase,omitempty"`
+	// Error from the server in reaction to the player's request (if any).
+	Error *HandlerError `json:"error,omitempty"`
+	// Sequence number of this message in the room (only set by the server).
+	Seq uint64 `json:"seq,omitempty"`
+	// Server time (in milliseconds since epoch) when this message was sent.
+	Timestamp int64 `json:"timestamp,omitempty"`
+	// Client-generated ID of the request (optional). The server echoes
+	// this in its replies (and errors) for that request.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// RoomCreationRequest from the client for creating a room.
+type RoomCreationRequest struct {
+	// Number of players to be allowed in that room.
+	Players uint8 `json:"players"`
+}
+
+// ReadyRequest from the client for toggling readiness in the lobby.
+type ReadyRequest struct {
+	// Whether the player is ready for the game to begin.
+	Ready bool `json:"ready"`
+}
+
+// TurnRequest for a player's attempt at submitting a card.
+type TurnRequest struct {
+	// Card submitted by the player in some round.
+	Card Card `json:"card"`
+	// Client-generated ID for this submission (optional). Submissions with
+	// the same ID are applied only once.
+	ActionID string `json:"actionId"`
+	// Turn for which this card was submitted (optional). Submissions for
+	// earlier turns are rejected.
+	Turn uint64 `json:"turn"`
+}
+
+// RoomResponse from the server.
+type RoomResponse struct {
+	// IDs of players in the room.
+	Players []string `json:"players"`
+	// When a player takes place of another player who has left the room, it should
+	// be possible to show the winner(s) in the room (if any).
+	Escaped []string `json:"escaped"`
+	// Max number of players allowed for this room.
+	Max uint8 `json:"max"`
+	// Index of the player taking the current turn.
+	TurnIdx uint8 `json:"turnIdx"`
+	// IDs of players who are ready for the next game.
+	Ready []string `json:"ready"`
+}
+
+// CountdownResponse from the server when all players are ready.
+type CountdownResponse struct {
+	// Seconds remaining until the cards are dealt. This is zero
+	// if the countdown has been cancelled.
+	Seconds uint8 `json:"seconds"`
+}
+
+// DealResponse from the server when the game begins.
+type DealResponse struct {
+	// Table containing IDs of players and the cards submitted by them for some round.
+	Table []PlayerCard `json:"table"`
+	// Hand of the player getting this response.
+	Hand []Card `json:"hand"`
+	// Whether this player is the dealer for this round.
+	IsDealer bool `json:"isDealer"`
+	// Whether this is the receiving player's turn.
+	OurTurn bool `json:"ourTurn"`
+	// Whose turn is this?
+	TurnPlayer string `json:"turnPlayer"`
+	// Number of cards in the hands of other players in the room.
+	OpponentHands map[string]uint8 `json:"opponentHands"`
+	// Cards which have left play after clean rounds in this game.
+	Discarded []Card `json:"discarded"`
+	// High rank cards handed to the player who's lost the previous game(s).
+	AceCards []Card `json:"aceCards"`
+	// Cards which this player is allowed to submit (if it's their turn).
+	LegalCards []Card `json:"legalCards"`
+	// Current turn in the room (for submitting cards).
+	Turn uint64 `json:"turn"`
+}
+
+// TrickResponse from the server at the end of each round.
+type TrickResponse struct {
+	// Table containing IDs of players and the cards submitted by them for this round.
+	Table []PlayerCard `json:"table"`
+	// ID of the player who led this round.
+	Leader string `json:"leader"`
+	// Suite of the card which led this round.
+	Suite string `json:"suite"`
+	// ID of the player who broke the suite (if any).
+	Breaker string `json:"breaker"`
+	// ID of the player who picked up the cards in the table (if the suite was broken).
+	PickedUpBy string `json:"pickedUpBy"`
+	// Number of cards picked up from the table.
+	PickedUp uint8 `json:"pickedUp"`
+	// ID of the dealer for the next round (if any).
+	Dealer string `json:"dealer"`
+}
+
+// Card from a deck.
+type Card struct {
+	Label string `json:"label"`
+	Suite string `json:"suite"`
+}
+
+// PlayerCard containing card with player ID.
+type PlayerCard struct {
+	ID   string `json:"id"`
+	Card Card   `json:"card"`
+}
@@ -0,0 +1,29 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadSettings(t *testing.T) {
+	assert := assert.New(t)
+	h := NewHub(Options{CleanupInterval: time.Hour})
+	defer h.Close()
+	conn := &memConnection{}
+	sess := &session{encoding: encodingJSON}
+
+	data := json.RawMessage(`{"players":8}`)
+	e := h.createRoomWithPlayer(conn, "big", "player1", sess, &data)
+	assert.Equal(errPlayerLimit, e.Code)
+
+	assert.NotNil(h.Reload(Options{MinPlayers: 9, MaxPlayers: 8}))
+	assert.Nil(h.Reload(Options{MaxPlayers: 8, RoomTimeout: time.Minute}))
+	assert.Nil(h.createRoomWithPlayer(conn, "big", "player1", sess, &data))
+	assert.EqualValues(minPlayers, h.settings().MinPlayers)
+	assert.Equal(time.Minute, h.settings().RoomTimeout)
+	// Settings which need a new hub are left alone.
+	assert.Equal(time.Hour, h.settings().CleanupInterval)
+}
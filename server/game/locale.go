@@ -0,0 +1,244 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// msgKey identifies a message in the catalog.
+type msgKey string
+
+const (
+	msgRoomMissingRestart  msgKey = "RoomMissingRestart"
+	msgRoomMissingCreate   msgKey = "RoomMissingCreate"
+	msgInvalidRoom         msgKey = "InvalidRoom"
+	msgRoomFull            msgKey = "RoomFull"
+	msgRoomExistsFull      msgKey = "RoomExistsFull"
+	msgPlayerExists        msgKey = "PlayerExists"
+	msgNotInRoom           msgKey = "NotInRoom"
+	msgInvalidRoomCreation msgKey = "InvalidRoomCreation"
+	msgInvalidTurn         msgKey = "InvalidTurn"
+	msgInvalidReady        msgKey = "InvalidReady"
+	msgPlayerLimit         msgKey = "PlayerLimit"
+	msgNotYourTurn         msgKey = "NotYourTurn"
+	msgCardMissing         msgKey = "CardMissing"
+	msgNotDealer           msgKey = "NotDealer"
+	msgIllegalMove         msgKey = "IllegalMove"
+	msgNotAllowed          msgKey = "NotAllowed"
+	msgInvalidPhase        msgKey = "InvalidPhase"
+	msgPlayerJoined        msgKey = "PlayerJoined"
+	msgPlayerLeft          msgKey = "PlayerLeft"
+	msgInvalidHello        msgKey = "InvalidHello"
+	msgUpgradeRequired     msgKey = "UpgradeRequired"
+	msgInvalidReplay       msgKey = "InvalidReplay"
+	msgStaleTurn           msgKey = "StaleTurn"
+	msgInvalidRequest      msgKey = "InvalidRequest"
+	msgRateLimited         msgKey = "RateLimited"
+	msgInvalidField        msgKey = "InvalidField"
+	msgMessageTooLarge     msgKey = "MessageTooLarge"
+	msgServerFull          msgKey = "ServerFull"
+	msgServerQueued        msgKey = "ServerQueued"
+	msgTooManyRooms        msgKey = "TooManyRooms"
+	msgTooManyConnections  msgKey = "TooManyConnections"
+	msgInternal            msgKey = "Internal"
+
+	defaultLanguage = "en"
+)
+
+// messageCatalog maps languages to their messages.
+type messageCatalog map[string]map[msgKey]string
+
+// Message catalog mapping languages to their messages. All languages
+// should have all the keys. If they don't, then we fallback to English.
+var catalog = messageCatalog{
+	"en": map[msgKey]string{
+		msgRoomMissingRestart:  "Room %s doesn't exist. Restart the game by creating a new room.",
+		msgRoomMissingCreate:   "Room %s doesn't exist. Feel free to create one!",
+		msgInvalidRoom:         "Invalid room specified.",
+		msgRoomFull:            "Room %s is full. Pick a different room.",
+		msgRoomExistsFull:      "Room %s already exists and is full. Choose a different name.",
+		msgPlayerExists:        "Player %s already exists in room %s. Choose a different name.",
+		msgNotInRoom:           "You don't belong in room %s. Please join the room first.",
+		msgInvalidRoomCreation: "Invalid request for creating room.",
+		msgInvalidTurn:         "Invalid request for player's turn.",
+		msgInvalidReady:        "Invalid request for player's readiness.",
+		msgPlayerLimit:         "Only %d-%d players are allowed.",
+		msgNotYourTurn:         "It's not your turn yet.",
+		msgCardMissing:         "You don't have that card.",
+		msgNotDealer:           "Only dealers are allowed to start a round.",
+		msgIllegalMove:         "Illegal move. You have %s%s which matches the suite in table.",
+		msgNotAllowed:          "You're not allowed to perform this action.",
+		msgInvalidPhase:        "You can't do that right now. The room is in %s phase.",
+		msgPlayerJoined:        "%s has joined the room.",
+		msgPlayerLeft:          "%s has left the room.",
+		msgInvalidHello:        "Invalid request for handshake.",
+		msgUpgradeRequired:     "Your version of the game is out of date. Please reload the page.",
+		msgInvalidReplay:       "Invalid request for replaying messages.",
+		msgStaleTurn:           "That card was meant for an earlier turn.",
+		msgInvalidRequest:      "Invalid request.",
+		msgInvalidField:        "Invalid message (%s).",
+		msgMessageTooLarge:     "Message is too large (at most %d bytes are allowed).",
+		msgServerFull:          "The server is full right now. Please try again later.",
+		msgServerQueued:        "The server is full right now. You're number %d in the queue for a room.",
+		msgTooManyRooms:        "Too many rooms have been created from your network. Please try again later.",
+		msgTooManyConnections:  "Too many connections from your network. Please close some tabs and try again.",
+		msgInternal:            "Something went wrong on the server. Please try again.",
+		msgRateLimited:         "You're doing that too often. Please slow down.",
+	},
+	"hi": map[msgKey]string{
+		msgRoomMissingRestart:  "कमरा %s मौजूद नहीं है। नया कमरा बनाकर खेल फिर से शुरू करें।",
+		msgRoomMissingCreate:   "कमरा %s मौजूद नहीं है। आप नया कमरा बना सकते हैं!",
+		msgInvalidRoom:         "अमान्य कमरा।",
+		msgRoomFull:            "कमरा %s भरा हुआ है। कोई दूसरा कमरा चुनें।",
+		msgRoomExistsFull:      "कमरा %s पहले से मौजूद है और भरा हुआ है। कोई दूसरा नाम चुनें।",
+		msgPlayerExists:        "खिलाड़ी %s पहले से कमरे %s में है। कोई दूसरा नाम चुनें।",
+		msgNotInRoom:           "आप कमरे %s में नहीं हैं। कृपया पहले कमरे में शामिल हों।",
+		msgInvalidRoomCreation: "कमरा बनाने के लिए अमान्य अनुरोध।",
+		msgInvalidTurn:         "खिलाड़ी की चाल के लिए अमान्य अनुरोध।",
+		msgInvalidReady:        "खिलाड़ी की तैयारी के लिए अमान्य अनुरोध।",
+		msgPlayerLimit:         "केवल %d-%d खिलाड़ियों की अनुमति है।",
+		msgNotYourTurn:         "अभी आपकी बारी नहीं है।",
+		msgCardMissing:         "आपके पास वह पत्ता नहीं है।",
+		msgNotDealer:           "केवल डीलर ही राउंड शुरू कर सकता है।",
+		msgIllegalMove:         "अवैध चाल। आपके पास %s%s है जो टेबल के रंग से मेल खाता है।",
+		msgNotAllowed:          "आपको यह करने की अनुमति नहीं है।",
+		msgInvalidPhase:        "आप अभी ऐसा नहीं कर सकते। कमरा %s चरण में है।",
+		msgPlayerJoined:        "%s कमरे में शामिल हुए।",
+		msgPlayerLeft:          "%s ने कमरा छोड़ दिया।",
+		msgInvalidHello:        "हैंडशेक के लिए अमान्य अनुरोध।",
+		msgUpgradeRequired:     "आपके खेल का संस्करण पुराना है। कृपया पेज को फिर से लोड करें।",
+		msgInvalidReplay:       "संदेशों को दोबारा भेजने के लिए अमान्य अनुरोध।",
+		msgStaleTurn:           "वह पत्ता पिछली बारी के लिए था।",
+		msgInvalidRequest:      "अमान्य अनुरोध।",
+		msgInvalidField:        "अमान्य संदेश (%s)।",
+		msgMessageTooLarge:     "संदेश बहुत बड़ा है (अधिकतम %d बाइट की अनुमति है)।",
+		msgServerFull:          "सर्वर अभी भरा हुआ है। कृपया बाद में फिर से प्रयास करें।",
+		msgServerQueued:        "सर्वर अभी भरा हुआ है। कमरे की कतार में आपका नंबर %d है।",
+		msgTooManyRooms:        "आपके नेटवर्क से बहुत सारे कमरे बनाए गए हैं। कृपया बाद में फिर से प्रयास करें।",
+		msgTooManyConnections:  "आपके नेटवर्क से बहुत सारे कनेक्शन हैं। कृपया कुछ टैब बंद करके फिर से प्रयास करें।",
+		msgInternal:            "सर्वर पर कुछ गड़बड़ हो गई। कृपया फिर से प्रयास करें।",
+		msgRateLimited:         "आप यह बहुत बार कर रहे हैं। कृपया थोड़ा धीमे चलें।",
+	},
+	"de": map[msgKey]string{
+		msgRoomMissingRestart:  "Raum %s existiert nicht. Starte das Spiel neu, indem du einen neuen Raum erstellst.",
+		msgRoomMissingCreate:   "Raum %s existiert nicht. Erstelle gerne einen!",
+		msgInvalidRoom:         "Ungültiger Raum angegeben.",
+		msgRoomFull:            "Raum %s ist voll. Wähle einen anderen Raum.",
+		msgRoomExistsFull:      "Raum %s existiert bereits und ist voll. Wähle einen anderen Namen.",
+		msgPlayerExists:        "Spieler %s ist bereits in Raum %s. Wähle einen anderen Namen.",
+		msgNotInRoom:           "Du gehörst nicht zu Raum %s. Bitte tritt dem Raum zuerst bei.",
+		msgInvalidRoomCreation: "Ungültige Anfrage zum Erstellen eines Raums.",
+		msgInvalidTurn:         "Ungültige Anfrage für den Spielzug.",
+		msgInvalidReady:        "Ungültige Anfrage zur Spielbereitschaft.",
+		msgPlayerLimit:         "Nur %d-%d Spieler sind erlaubt.",
+		msgNotYourTurn:         "Du bist noch nicht dran.",
+		msgCardMissing:         "Du hast diese Karte nicht.",
+		msgNotDealer:           "Nur der Geber darf eine Runde beginnen.",
+		msgIllegalMove:         "Ungültiger Zug. Du hast %s%s, das zur Farbe auf dem Tisch passt.",
+		msgNotAllowed:          "Du darfst diese Aktion nicht ausführen.",
+		msgInvalidPhase:        "Das geht gerade nicht. Der Raum ist in der Phase %s.",
+		msgPlayerJoined:        "%s ist dem Raum beigetreten.",
+		msgPlayerLeft:          "%s hat den Raum verlassen.",
+		msgInvalidHello:        "Ungültige Anfrage für den Handshake.",
+		msgUpgradeRequired:     "Deine Version des Spiels ist veraltet. Bitte lade die Seite neu.",
+		msgInvalidReplay:       "Ungültige Anfrage zum erneuten Senden von Nachrichten.",
+		msgStaleTurn:           "Diese Karte war für einen früheren Zug gedacht.",
+		msgInvalidRequest:      "Ungültige Anfrage.",
+		msgInvalidField:        "Ungültige Nachricht (%s).",
+		msgMessageTooLarge:     "Nachricht ist zu groß (höchstens %d Bytes sind erlaubt).",
+		msgServerFull:          "Der Server ist gerade voll. Bitte versuche es später noch einmal.",
+		msgServerQueued:        "Der Server ist gerade voll. Du bist Nummer %d in der Warteschlange für einen Raum.",
+		msgTooManyRooms:        "Aus deinem Netzwerk wurden zu viele Räume erstellt. Bitte versuche es später noch einmal.",
+		msgTooManyConnections:  "Zu viele Verbindungen aus deinem Netzwerk. Bitte schließe einige Tabs und versuche es erneut.",
+		msgInternal:            "Auf dem Server ist etwas schiefgelaufen. Bitte versuche es noch einmal.",
+		msgRateLimited:         "Du machst das zu oft. Bitte etwas langsamer.",
+	},
+	"fr": map[msgKey]string{
+		msgRoomMissingRestart:  "La salle %s n'existe pas. Relancez la partie en créant une nouvelle salle.",
+		msgRoomMissingCreate:   "La salle %s n'existe pas. N'hésitez pas à en créer une !",
+		msgInvalidRoom:         "Salle spécifiée invalide.",
+		msgRoomFull:            "La salle %s est pleine. Choisissez une autre salle.",
+		msgRoomExistsFull:      "La salle %s existe déjà et est pleine. Choisissez un autre nom.",
+		msgPlayerExists:        "Le joueur %s existe déjà dans la salle %s. Choisissez un autre nom.",
+		msgNotInRoom:           "Vous ne faites pas partie de la salle %s. Veuillez d'abord la rejoindre.",
+		msgInvalidRoomCreation: "Requête invalide pour créer une salle.",
+		msgInvalidTurn:         "Requête invalide pour le tour du joueur.",
+		msgInvalidReady:        "Requête invalide pour l'état prêt du joueur.",
+		msgPlayerLimit:         "Seuls %d à %d joueurs sont autorisés.",
+		msgNotYourTurn:         "Ce n'est pas encore votre tour.",
+		msgCardMissing:         "Vous n'avez pas cette carte.",
+		msgNotDealer:           "Seul le donneur peut commencer un tour.",
+		msgIllegalMove:         "Coup illégal. Vous avez %s%s qui correspond à la couleur sur la table.",
+		msgNotAllowed:          "Vous n'êtes pas autorisé à effectuer cette action.",
+		msgInvalidPhase:        "Vous ne pouvez pas faire cela maintenant. La salle est en phase %s.",
+		msgPlayerJoined:        "%s a rejoint la salle.",
+		msgPlayerLeft:          "%s a quitté la salle.",
+		msgInvalidHello:        "Requête invalide pour la poignée de main.",
+		msgUpgradeRequired:     "Votre version du jeu est obsolète. Veuillez recharger la page.",
+		msgInvalidReplay:       "Requête invalide pour renvoyer les messages.",
+		msgStaleTurn:           "Cette carte était destinée à un tour précédent.",
+		msgInvalidRequest:      "Requête invalide.",
+		msgInvalidField:        "Message invalide (%s).",
+		msgMessageTooLarge:     "Le message est trop volumineux (%d octets au maximum).",
+		msgServerFull:          "Le serveur est plein pour le moment. Veuillez réessayer plus tard.",
+		msgServerQueued:        "Le serveur est plein pour le moment. Vous êtes numéro %d dans la file d'attente pour une salle.",
+		msgTooManyRooms:        "Trop de salles ont été créées depuis votre réseau. Veuillez réessayer plus tard.",
+		msgTooManyConnections:  "Trop de connexions depuis votre réseau. Veuillez fermer quelques onglets et réessayer.",
+		msgInternal:            "Une erreur est survenue sur le serveur. Veuillez réessayer.",
+		msgRateLimited:         "Vous faites cela trop souvent. Veuillez ralentir.",
+	},
+}
+
+// translate the message for the given key into the given language.
+func translate(lang string, key msgKey, args ...interface{}) string {
+	return catalog.translate(lang, key, args...)
+}
+
+// translate the message for the given key into the given language
+// using this catalog.
+func (c messageCatalog) translate(lang string, key msgKey, args ...interface{}) string {
+	format, exists := c[lang][key]
+	if !exists {
+		format = c[defaultLanguage][key]
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+// supportedLanguage returns the language in the catalog matching the
+// given language tag (e.g., `de-AT` gives `de`), or an empty string.
+func supportedLanguage(tag string) string {
+	lang := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	if _, exists := catalog[lang]; exists {
+		return lang
+	}
+
+	return ""
+}
+
+// preferredLanguage from the value of an `Accept-Language` header. Browsers
+// order the tags by preference, so we pick the first one in our catalog.
+func preferredLanguage(header string) string {
+	for _, tag := range strings.Split(header, ",") {
+		if i := strings.Index(tag, ";"); i >= 0 {
+			tag = tag[:i] // ignore quality
+		}
+
+		if lang := supportedLanguage(tag); lang != "" {
+			return lang
+		}
+	}
+
+	return defaultLanguage
+}
+
+// localize the message of this error in the given language.
+func (e *HandlerError) localize(lang string) {
+	if e.key != "" {
+		e.Msg = translate(lang, e.key, e.args...)
+	}
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogCompleteness(t *testing.T) {
+	assert := assert.New(t)
+	for lang, messages := range catalog {
+		assert.Len(messages, len(catalog[defaultLanguage]), "language: %s", lang)
+		for key := range catalog[defaultLanguage] {
+			assert.Contains(messages, key, "language: %s", lang)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("de", preferredLanguage("de-AT,de;q=0.9,en;q=0.8"))
+	assert.Equal("hi", preferredLanguage("ta-IN, hi-IN;q=0.9, en;q=0.5"))
+	assert.Equal("fr", preferredLanguage("FR_fr"))
+	assert.Equal(defaultLanguage, preferredLanguage("ja-JP"))
+	assert.Equal(defaultLanguage, preferredLanguage(""))
+
+	assert.Equal("", supportedLanguage("ja"))
+	assert.Equal("en", supportedLanguage(" en-GB "))
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	assert := assert.New(t)
+	e := &HandlerError{
+		Code: errPlayerLimit,
+		key:  msgPlayerLimit,
+		args: []interface{}{minPlayers, maxPlayers},
+	}
+
+	e.localize("en")
+	assert.Equal("Only 3-6 players are allowed.", e.Msg)
+	e.localize("de")
+	assert.Equal("Nur 3-6 Spieler sind erlaubt.", e.Msg)
+	e.localize("xx")
+	assert.Equal("Only 3-6 players are allowed.", e.Msg)
+
+	// Unknown keys for some language fall back to English.
+	partial := messageCatalog{
+		defaultLanguage: catalog[defaultLanguage],
+		"fr": map[msgKey]string{
+			msgPlayerLimit: catalog["fr"][msgPlayerLimit],
+		},
+	}
+
+	assert.Equal("It's not your turn yet.", partial.translate("fr", msgNotYourTurn))
+	assert.Equal(translate("fr", msgPlayerLimit, 3, 6), partial.translate("fr", msgPlayerLimit, 3, 6))
+}
+
+func TestNoticeLanguage(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[]"})
+	defer h.Close()
+
+	sess := &session{lang: "en", encoding: encodingJSON}
+	p1 := room.players["player1"]
+	p1.sess = sess
+	room.broadcastNotice(msgPlayerLeft, "player2")
+	assert.Equal(translate("en", msgPlayerLeft, "player2"), p1.conn.(*memConnection).take()[0].Msg)
+
+	// Changing the locale later on applies to the notices as well.
+	h.handle(p1.conn, sess, &GameMessage{Event: eventHello, Locale: "de"})
+	p1.conn.(*memConnection).take()
+	room.broadcastNotice(msgPlayerLeft, "player2")
+	assert.Equal(translate("de", msgPlayerLeft, "player2"), p1.conn.(*memConnection).take()[0].Msg)
+}
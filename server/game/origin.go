@@ -0,0 +1,91 @@
+package game
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// validOrigin checks whether the given entry can be used in the allowed origins.
+func validOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return fmt.Errorf("invalid origin %q (should be like https://example.com)", origin)
+	}
+
+	return nil
+}
+
+// allowedOrigin checks whether the origin of the given request is allowed.
+// Only the server's own origin is allowed if the hub hasn't been given a list.
+func (hub *Hub) allowedOrigin(r *http.Request, origin string) bool {
+	origin = strings.TrimRight(origin, "/")
+	allowed := hub.settings().AllowedOrigins
+	if len(allowed) == 0 {
+		return strings.EqualFold(origin, hub.requestScheme(r)+"://"+r.Host)
+	}
+
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkWebsocketOrigin during the websocket handshake, so that other sites
+// can't open sockets from their visitors' browsers. Like `withCORS`, sockets
+// without an origin aren't from browsers, so they're let through.
+func (hub *Hub) checkWebsocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil || origin == nil {
+		return err
+	}
+
+	config.Origin = origin
+	if !hub.allowedOrigin(r, origin.String()) {
+		hub.opts.Logger.Printf("Rejecting websocket from %s with origin %s\n", hub.clientAddr(r), origin)
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+
+	return nil
+}
+
+// withCORS enforces the allowed origins on the HTTP endpoints and answers
+// preflight requests. Requests without an origin aren't from browsers, so
+// they're let through.
+func (hub *Hub) withCORS(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !hub.allowedOrigin(r, origin) {
+			hub.opts.Logger.Printf("Rejecting request from %s with origin %s\n", hub.clientAddr(r), origin)
+			http.Error(w, "Origin not allowed.", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestAllowedOrigins(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour})
+	defer hub.Close()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	assert.Nil(err)
+	ws.Close()
+
+	_, err = websocket.Dial(wsURL, "", "https://evil.example")
+	assert.NotNil(err)
+
+	// Clients other than browsers don't send an origin.
+	r, _ := http.NewRequest("GET", server.URL+"/ws", nil)
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	resp, err := http.DefaultClient.Do(r)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+
+	request := func(method, path, origin string) *http.Response {
+		r, _ := http.NewRequest(method, server.URL+path, nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", "POST")
+		resp, err := http.DefaultClient.Do(r)
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp = request("POST", "/api/rooms", "https://evil.example")
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	resp = request("POST", "/http/connect", server.URL)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(server.URL, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	// Other sites can be allowed explicitly.
+	assert.Nil(hub.Reload(Options{AllowedOrigins: []string{"https://friend.example"}}))
+	resp = request("OPTIONS", "/api/rooms", "https://friend.example")
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+	assert.Equal("https://friend.example", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+
+	ws, err = websocket.Dial(wsURL, "", "https://friend.example")
+	assert.Nil(err)
+	ws.Close()
+	_, err = websocket.Dial(wsURL, "", server.URL)
+	assert.NotNil(err)
+
+	assert.NotNil((&Options{AllowedOrigins: []string{"friend.example"}}).Validate())
+	assert.NotNil((&Options{AllowedOrigins: []string{"https://friend.example/game"}}).Validate())
+	assert.Nil((&Options{AllowedOrigins: []string{"*", "http://localhost:8080"}}).Validate())
+}
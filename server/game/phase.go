@@ -0,0 +1,89 @@
+package game
+
+import (
+	"fmt"
+)
+
+// roomPhase represents the stage of the game in some room.
+type roomPhase string
+
+const (
+	// Players are joining the room and/or getting ready.
+	phaseLobby roomPhase = "Lobby"
+	// Everyone's ready and the cards are about to be dealt.
+	phaseDealing roomPhase = "Dealing"
+	// Players are submitting cards to the table.
+	phaseInTrick roomPhase = "InTrick"
+	// All cards for this round are in the table (or someone has
+	// broken the suite) and the table is about to be cleared.
+	phaseTrickResolved roomPhase = "TrickResolved"
+	// Someone has lost the game (or it's a tie).
+	phaseGameOver roomPhase = "GameOver"
+)
+
+var (
+	// Phases that a room is allowed to move to from some phase.
+	phaseTransitions = map[roomPhase][]roomPhase{
+		phaseLobby: []roomPhase{phaseDealing},
+		// Dealing can be cancelled if someone backs out during the countdown.
+		phaseDealing: []roomPhase{phaseInTrick, phaseLobby},
+		// Games can be restarted midway, in which case we go back to the lobby.
+		phaseInTrick:       []roomPhase{phaseTrickResolved, phaseLobby},
+		phaseTrickResolved: []roomPhase{phaseInTrick, phaseGameOver},
+		phaseGameOver:      []roomPhase{phaseLobby},
+	}
+
+	// Phases in which players are allowed to send some event. Events
+	// which aren't here are allowed in all phases.
+	eventPhases = map[string][]roomPhase{
+		eventPlayerTurn:     []roomPhase{phaseInTrick},
+		eventPlayerReady:    []roomPhase{phaseLobby, phaseDealing, phaseGameOver},
+		eventNewGameRequest: []roomPhase{phaseInTrick},
+		eventResync:         []roomPhase{phaseInTrick, phaseGameOver},
+	}
+)
+
+// containsPhase checks whether the given phase exists in the collection.
+func containsPhase(phases []roomPhase, phase roomPhase) bool {
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setPhase moves this room to the given phase if the transition is valid.
+//
+// **NOTE:** The caller is responsible for synchronizing access to room pointer.
+func (r *Room) setPhase(phase roomPhase) error {
+	if !containsPhase(phaseTransitions[r.phase], phase) {
+		return fmt.Errorf("invalid transition from %s to %s", r.phase, phase)
+	}
+
+	r.phase = phase
+	return nil
+}
+
+// inGame checks whether a game is being played in this room.
+func (r *Room) inGame() bool {
+	return r.phase == phaseDealing || r.phase == phaseInTrick || r.phase == phaseTrickResolved
+}
+
+// checkEventPhase returns an error if the given event isn't allowed
+// in the current phase of this room.
+func (r *Room) checkEventPhase(event string) *HandlerError {
+	phases, exists := eventPhases[event]
+	if !exists || containsPhase(phases, r.phase) {
+		return nil
+	}
+
+	return &HandlerError{
+		Code:    errInvalidPhase,
+		key:     msgInvalidPhase,
+		args:    []interface{}{r.phase},
+		Event:   eventInvalidPhase,
+		Details: &PhaseDetails{Phase: r.phase},
+	}
+}
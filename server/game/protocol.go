@@ -0,0 +1,148 @@
+package game
+
+import "encoding/json"
+
+const (
+	// Version of the protocol spoken by this server. This should be bumped
+	// whenever the shape of the messages changes.
+	protocolVersion = 2
+	// Oldest version of the protocol still supported by this server.
+	minProtocolVersion = 2
+)
+
+// Optional features supported by this server. Clients declare the ones
+// they understand during the handshake. Everything else (readiness, phases,
+// tricks, legal cards, error codes, locales and replays) is part of the
+// protocol version itself.
+var serverFeatures = []string{
+	"acks",
+	"deltas",
+}
+
+// HelloRequest from the client for initiating the handshake.
+type HelloRequest struct {
+	// Version of the protocol spoken by the client.
+	Version uint `json:"version"`
+	// Optional features understood by the client.
+	Capabilities []string `json:"capabilities"`
+	// Encodings understood by the client (in the order of its preference).
+	// Messages are sent as JSON if this is empty.
+	Encodings []wireEncoding `json:"encodings"`
+}
+
+// HelloResponse from the server after the handshake.
+type HelloResponse struct {
+	// Version of the protocol spoken by the server.
+	Version uint `json:"version"`
+	// Oldest version of the protocol supported by the server.
+	MinVersion uint `json:"minVersion"`
+	// Optional features supported by the server.
+	Features []string `json:"features"`
+	// Features which will be used for this connection (i.e., supported by both).
+	Enabled []string `json:"enabled"`
+	// Encoding for all messages after this response.
+	Encoding wireEncoding `json:"encoding"`
+}
+
+// negotiate the features to be used for a connection, given the features
+// and encodings offered by the server. Returns `false` if the client's
+// version of the protocol isn't supported anymore.
+func negotiate(req *HelloRequest, features []string, encodings []wireEncoding) (*HelloResponse, bool) {
+	resp := &HelloResponse{
+		Version:    protocolVersion,
+		MinVersion: minProtocolVersion,
+		Features:   features,
+		Enabled:    make([]string, 0),
+		Encoding:   encodingJSON,
+	}
+
+	if req.Version < minProtocolVersion {
+		return resp, false
+	}
+
+	for _, f := range features {
+		if hasFeature(req.Capabilities, f) {
+			resp.Enabled = append(resp.Enabled, f)
+		}
+	}
+
+	// Client's preference wins, since it knows which encoding it handles better.
+	for _, e := range req.Encodings {
+		if hasEncoding(encodings, e) {
+			resp.Encoding = e
+			break
+		}
+	}
+
+	return resp, true
+}
+
+// AckResponse from the server after accepting some request.
+type AckResponse struct {
+	// Event of the request which has been accepted.
+	Event string `json:"event"`
+}
+
+// greet the client with the server's version and features. Returns the
+// settings negotiated for this connection.
+func (hub *Hub) greet(conn connection, sess *session, requestID string, data *json.RawMessage) (*HelloResponse, *HandlerError) {
+	var req HelloRequest
+	if data == nil || json.Unmarshal(*data, &req) != nil {
+		return nil, &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidHello,
+		}
+	}
+
+	resp, ok := negotiate(&req, hub.features, hub.encodings)
+	if !ok {
+		return nil, hub.upgradeRequired()
+	}
+
+	if !sess.binary {
+		resp.Encoding = encodingJSON
+	}
+
+	// Clients switch to the negotiated encoding only after this response.
+	sendMessage(conn, &GameMessage{
+		Event:     eventHello,
+		Response:  resp,
+		RequestID: requestID,
+	})
+
+	return resp, nil
+}
+
+// hasFeature checks whether the given feature has been enabled for some connection.
+func hasFeature(features []string, feature string) bool {
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasEncoding checks whether the given encoding is in the list.
+func hasEncoding(encodings []wireEncoding, enc wireEncoding) bool {
+	for _, e := range encodings {
+		if e == enc {
+			return true
+		}
+	}
+
+	return false
+}
+
+// upgradeRequired returns the error for clients whose version of
+// the protocol isn't supported by this server.
+func (hub *Hub) upgradeRequired() *HandlerError {
+	resp, _ := negotiate(&HelloRequest{}, hub.features, hub.encodings)
+	return &HandlerError{
+		Code:    errUpgradeRequired,
+		key:     msgUpgradeRequired,
+		Event:   eventUpgradeRequired,
+		Details: resp,
+	}
+}
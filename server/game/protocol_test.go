@@ -0,0 +1,90 @@
+package game
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	assert := assert.New(t)
+
+	resp, ok := negotiate(&HelloRequest{
+		Version:      protocolVersion,
+		Capabilities: []string{"deltas", "teleportation"},
+	}, serverFeatures, serverEncodings)
+	assert.True(ok)
+	assert.EqualValues(protocolVersion, resp.Version)
+	assert.EqualValues(minProtocolVersion, resp.MinVersion)
+	assert.Equal(serverFeatures, resp.Features)
+	assert.Equal([]string{"deltas"}, resp.Enabled)
+	assert.True(hasFeature(resp.Enabled, "deltas"))
+	assert.False(hasFeature(resp.Enabled, "acks"))
+
+	assert.Equal(encodingJSON, resp.Encoding)
+
+	resp, _ = negotiate(&HelloRequest{
+		Version:   protocolVersion,
+		Encodings: []wireEncoding{"cbor", encodingMsgpack, encodingJSON},
+	}, serverFeatures, serverEncodings)
+	assert.Equal(encodingMsgpack, resp.Encoding)
+
+	// Disabled features and encodings aren't offered.
+	resp, _ = negotiate(&HelloRequest{
+		Version:      protocolVersion,
+		Capabilities: []string{"acks", "deltas"},
+		Encodings:    []wireEncoding{encodingMsgpack, encodingJSON},
+	}, []string{"acks"}, []wireEncoding{encodingJSON})
+	assert.Equal([]string{"acks"}, resp.Enabled)
+	assert.Equal(encodingJSON, resp.Encoding)
+
+	// Newer clients are expected to fall back to our version.
+	_, ok = negotiate(&HelloRequest{Version: protocolVersion + 1}, serverFeatures, serverEncodings)
+	assert.True(ok)
+
+	// Clients which don't declare a version are too old.
+	resp, ok = negotiate(&HelloRequest{}, serverFeatures, serverEncodings)
+	assert.False(ok)
+	assert.Empty(resp.Enabled)
+
+	hub := NewHub(Options{CleanupInterval: time.Hour, Disable: []string{"acks"}})
+	defer hub.Close()
+	e := hub.upgradeRequired()
+	assert.Equal(errUpgradeRequired, e.Code)
+	assert.Equal(eventUpgradeRequired, e.Event)
+	assert.NotContains(e.Details.(*HelloResponse).Features, "acks")
+}
+
+func TestValidateOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil((&Options{}).Validate())
+	assert.Nil((&Options{MinPlayers: 2, MaxPlayers: 8, Disable: []string{"msgpack", "api"}}).Validate())
+	assert.NotNil((&Options{MinPlayers: 7}).Validate())
+	assert.NotNil((&Options{MaxPlayers: 40}).Validate())
+	assert.NotNil((&Options{RoomTimeout: -time.Second}).Validate())
+	assert.NotNil((&Options{Disable: []string{"teleportation"}}).Validate())
+	// Parts of the protocol itself can't be turned off.
+	assert.NotNil((&Options{Disable: []string{"phases"}}).Validate())
+}
+
+func TestToggles(t *testing.T) {
+	assert := assert.New(t)
+	offered := func(h *Hub, name string) bool {
+		_, pattern := h.mux.Handler(httptest.NewRequest("GET", "/"+name+"/", nil))
+		return hasFeature(h.features, name) || hasEncoding(h.encodings, wireEncoding(name)) || pattern == "/"+name+"/"
+	}
+
+	// Everything which can be disabled is gone from the hub when it's disabled.
+	for _, name := range Toggles() {
+		h := NewHub(Options{CleanupInterval: time.Hour})
+		assert.True(offered(h, name), name)
+		h.Close()
+
+		h = NewHub(Options{CleanupInterval: time.Hour, Disable: []string{name}})
+		assert.False(offered(h, name), name)
+		h.Close()
+	}
+}
@@ -0,0 +1,210 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// Limited events are forgiven once a client behaves for this long.
+	rateViolationWindow = 10 * time.Second
+	// Default number of limited events (within the window) after which
+	// a connection is dropped.
+	maxRateViolations = 20
+	// How long the limits of some client address are remembered after its
+	// last event (its buckets would've been refilled by then).
+	rateLimiterTimeout = time.Minute
+)
+
+// RateLimit for some type of event, as a token bucket which is refilled at
+// some rate and which can hold a number of tokens for bursts.
+type RateLimit struct {
+	// Events allowed per second.
+	Rate float64 `json:"rate"`
+	// Events allowed at once.
+	Burst float64 `json:"burst"`
+}
+
+// Default limits for events from each connection. Chat is limited more
+// than the rest, since it's sent to everyone in the room.
+var connRateLimits = map[string]RateLimit{
+	eventPlayerMsg:  {Rate: 1, Burst: 5},
+	eventPlayerTurn: {Rate: 5, Burst: 10},
+	"*":             {Rate: 10, Burst: 20},
+}
+
+// Default limits for events from each client address (across its connections).
+var addrRateLimits = map[string]RateLimit{
+	eventPlayerMsg: {Rate: 3, Burst: 15},
+	"*":            {Rate: 30, Burst: 60},
+}
+
+// validRateLimits checks whether the given limits make sense.
+func validRateLimits(limits map[string]RateLimit) error {
+	for event, l := range limits {
+		if event == "" || l.Rate <= 0 || l.Burst < 1 {
+			return fmt.Errorf("invalid rate limit for %q (rate should be positive and burst should be at least 1)", event)
+		}
+	}
+
+	return nil
+}
+
+// copyRateLimits returns a copy of the given limits.
+func copyRateLimits(limits map[string]RateLimit) map[string]RateLimit {
+	copied := make(map[string]RateLimit, len(limits))
+	for event, l := range limits {
+		copied[event] = l
+	}
+
+	return copied
+}
+
+// tokenBucket for some type of event.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter tracks the events of some client with a token bucket for each
+// type of event (events without a limit of their own share the `*` bucket).
+type rateLimiter struct {
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	// Number of events which have been limited recently.
+	violations int
+	// Last time an event was limited.
+	lastViolation time.Time
+	// Last time an event was seen.
+	lastSeen time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// allow the given event as per the limits. If it's not allowed, then this
+// also returns how long the client should wait before trying again.
+func (l *rateLimiter) allow(event string, limits map[string]RateLimit, now time.Time) (bool, time.Duration) {
+	limit, exists := limits[event]
+	if !exists {
+		event = "*"
+		if limit, exists = limits[event]; !exists {
+			return true, 0
+		}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lastSeen = now
+	b := l.buckets[event]
+	if b == nil {
+		b = &tokenBucket{tokens: limit.Burst, last: now}
+		l.buckets[event] = b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(limit.Burst, b.tokens+elapsed*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / limit.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// strike records a limited event and returns the number of limited events
+// since the client last behaved.
+func (l *rateLimiter) strike(now time.Time) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if now.Sub(l.lastViolation) > rateViolationWindow {
+		l.violations = 0
+	}
+
+	l.violations++
+	l.lastViolation = now
+	return l.violations
+}
+
+// idleSince checks whether the client hasn't sent any events since the given time.
+func (l *rateLimiter) idleSince(t time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.lastSeen.Before(t)
+}
+
+// RateLimitDetails for `errRateLimited`.
+type RateLimitDetails struct {
+	// Event which has been limited.
+	Event string `json:"event"`
+	// Milliseconds after which the event will be allowed again.
+	RetryAfter int64 `json:"retryAfter"`
+}
+
+// addrLimiter returns the limiter for the given client address.
+func (hub *Hub) addrLimiter(addr string) *rateLimiter {
+	hub.limitersLock.Lock()
+	defer hub.limitersLock.Unlock()
+	l := hub.addrLimiters[addr]
+	if l == nil {
+		l = newRateLimiter()
+		hub.addrLimiters[addr] = l
+	}
+
+	return l
+}
+
+// pruneLimiters forgets the addresses which haven't sent anything in a while.
+func (hub *Hub) pruneLimiters(now time.Time) {
+	hub.limitersLock.Lock()
+	defer hub.limitersLock.Unlock()
+	for addr, l := range hub.addrLimiters {
+		if l.idleSince(now.Add(-rateLimiterTimeout)) {
+			delete(hub.addrLimiters, addr)
+		}
+	}
+}
+
+// limitRate checks the event against the limits for the session and its
+// address. Returns an error if the event has been limited, and `false` if
+// the client has been flooding for long enough that it should be dropped.
+func (hub *Hub) limitRate(sess *session, event string) (*HandlerError, bool) {
+	opts, now := hub.settings(), time.Now()
+	if sess.limiter == nil {
+		sess.limiter = newRateLimiter()
+	}
+
+	ok, wait := sess.limiter.allow(event, opts.ConnRateLimits, now)
+	if ok {
+		ok, wait = hub.addrLimiter(sess.addr).allow(event, opts.AddrRateLimits, now)
+	}
+
+	if ok {
+		return nil, true
+	}
+
+	if sess.limiter.strike(now) > opts.MaxRateViolations {
+		hub.opts.Logger.Printf("Dropping connection from %s after sustained flooding.\n", sess.addr)
+		return nil, false
+	}
+
+	return rateLimited(event, wait), true
+}
+
+// rateLimited returns the error for an event which has been limited.
+func rateLimited(event string, wait time.Duration) *HandlerError {
+	return &HandlerError{
+		Code:  errRateLimited,
+		key:   msgRateLimited,
+		Event: eventRateLimited,
+		Details: &RateLimitDetails{
+			Event:      event,
+			RetryAfter: int64(math.Ceil(wait.Seconds() * 1000)),
+		},
+	}
+}
@@ -0,0 +1,109 @@
+package game
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	assert := assert.New(t)
+	limits := map[string]RateLimit{
+		eventPlayerMsg: {Rate: 1, Burst: 2},
+		"*":            {Rate: 10, Burst: 1},
+	}
+
+	l, now := newRateLimiter(), time.Now()
+	ok, _ := l.allow(eventPlayerMsg, limits, now)
+	assert.True(ok)
+	ok, _ = l.allow(eventPlayerMsg, limits, now)
+	assert.True(ok)
+	ok, wait := l.allow(eventPlayerMsg, limits, now)
+	assert.False(ok)
+	assert.Equal(time.Second, wait)
+
+	// Other events have their own bucket.
+	ok, _ = l.allow(eventPlayerTurn, limits, now)
+	assert.True(ok)
+	ok, _ = l.allow(eventPlayerReady, limits, now)
+	assert.False(ok)
+
+	ok, _ = l.allow(eventPlayerMsg, limits, now.Add(time.Second))
+	assert.True(ok)
+
+	// Events without a limit are always allowed.
+	ok, _ = l.allow(eventPlayerMsg, map[string]RateLimit{}, now)
+	assert.True(ok)
+
+	assert.Equal(1, l.strike(now))
+	assert.Equal(2, l.strike(now.Add(time.Second)))
+	assert.Equal(1, l.strike(now.Add(time.Minute)))
+
+	assert.NotNil(validRateLimits(map[string]RateLimit{"*": {Rate: 0, Burst: 1}}))
+	assert.NotNil(validRateLimits(map[string]RateLimit{"*": {Rate: 1, Burst: 0.5}}))
+	assert.Nil(validRateLimits(connRateLimits))
+}
+
+func TestFloodingConnection(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{
+		CleanupInterval:   time.Hour,
+		ConnRateLimits:    map[string]RateLimit{eventPlayerMsg: {Rate: 0.001, Burst: 2}},
+		MaxRateViolations: 3,
+	})
+	defer hub.Close()
+
+	conn := &memConnection{}
+	sess := &session{addr: "192.0.2.1", encoding: encodingJSON, features: []string{}}
+	msg := &GameMessage{Event: eventPlayerMsg, Player: "spammer", Room: "attic", Msg: "hi"}
+	assert.True(hub.handle(conn, sess, msg))
+	assert.True(hub.handle(conn, sess, msg))
+	conn.take()
+
+	for i := 0; i < 3; i++ {
+		assert.True(hub.handle(conn, sess, msg))
+		sent := conn.take()
+		assert.Len(sent, 1)
+		assert.Equal(eventRateLimited, sent[0].Event)
+		assert.Equal(errRateLimited, sent[0].Error.Code)
+	}
+
+	// Clients which keep going are dropped.
+	assert.False(hub.handle(conn, sess, msg))
+
+	// Other connections from the same address are limited by their own buckets.
+	other := &session{addr: "192.0.2.1", encoding: encodingJSON, features: []string{}}
+	assert.True(hub.handle(conn, other, msg))
+	sent := conn.take()
+	assert.Len(sent, 1)
+	assert.Equal(errRoomMissing, sent[0].Error.Code)
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{
+		CleanupInterval: time.Hour,
+		AddrRateLimits:  map[string]RateLimit{eventRoomCreate: {Rate: 0.001, Burst: 1}},
+	})
+	defer hub.Close()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	create := func(room string) *http.Response {
+		body := `{"room": "` + room + `", "player": "alice", "players": 3}`
+		resp, err := http.Post(server.URL+"/api/rooms", "application/json", strings.NewReader(body))
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(http.StatusOK, create("attic").StatusCode)
+	resp := create("cellar")
+	assert.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(resp.Header.Get("Retry-After"))
+}
@@ -0,0 +1,164 @@
+package game
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Max number of outbound messages buffered in a room for replaying.
+const replayBufferSize = 256
+
+// bufferedMessage sent to some player in a room.
+type bufferedMessage struct {
+	// Sequence number of this message in the room.
+	seq uint64
+	// ID of the player who received this message.
+	playerID string
+	// Encoded message.
+	data []byte
+}
+
+// ReplayRequest from the client for messages it's missed.
+type ReplayRequest struct {
+	// Sequence number of the last message seen by the client.
+	Since uint64 `json:"since"`
+}
+
+// ReplayResponse from the server before replaying messages.
+type ReplayResponse struct {
+	// Sequence number of the last message seen by the client.
+	Since uint64 `json:"since"`
+	// Number of messages being replayed.
+	Count uint16 `json:"count"`
+	// Whether the buffer had all the messages missed by the client. If it
+	// didn't, then the server also sends the current state of the room.
+	Complete bool `json:"complete"`
+}
+
+// record the encoded message sent to the given player. Old messages
+// are dropped once the buffer reaches its limit.
+func (r *Room) record(playerID string, seq uint64, data []byte) {
+	r.history = append(r.history, bufferedMessage{
+		seq:      seq,
+		playerID: playerID,
+		data:     data,
+	})
+
+	if len(r.history) > replayBufferSize {
+		r.history = r.history[len(r.history)-replayBufferSize:]
+	}
+}
+
+// messagesSince returns the buffered messages sent to the given player
+// after the given sequence number, along with whether the buffer has
+// all of those messages.
+func (r *Room) messagesSince(playerID string, since uint64) ([][]byte, bool) {
+	messages := make([][]byte, 0)
+	complete := len(r.history) == 0 || r.history[0].seq <= since+1
+	for _, m := range r.history {
+		if m.seq > since && m.playerID == playerID {
+			messages = append(messages, m.data)
+		}
+	}
+
+	return messages, complete
+}
+
+// stamp the message with the next sequence number in this room and the server time.
+func (r *Room) stamp(msg *GameMessage) {
+	r.seq++
+	msg.Seq = r.seq
+	msg.Phase = r.phase
+	msg.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// replayMessages sends the messages missed by a (re)joining player.
+func (hub *Hub) replayMessages(conn connection, roomID, playerID, requestID string, data *json.RawMessage) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgRoomMissingCreate,
+			args:    []interface{}{roomID},
+			Event:   eventRoomMissing,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	defer room.lock.Unlock()
+
+	player, exists := room.players[playerID]
+	if !exists || player.conn != conn {
+		return &HandlerError{
+			Code:    errNotInRoom,
+			key:     msgNotInRoom,
+			args:    []interface{}{roomID},
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	var req ReplayRequest
+	if data == nil || json.Unmarshal(*data, &req) != nil {
+		return &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidReplay,
+		}
+	}
+
+	messages, complete := room.messagesSince(playerID, req.Since)
+	sendMessage(conn, &GameMessage{
+		Player: playerID,
+		Room:   roomID,
+		Event:  eventReplay,
+		Phase:  room.phase,
+		Response: &ReplayResponse{
+			Since:    req.Since,
+			Count:    uint16(len(messages)),
+			Complete: complete,
+		},
+		RequestID: requestID,
+	})
+
+	for _, m := range messages {
+		conn.send(m)
+	}
+
+	if complete {
+		return nil
+	}
+
+	// Some messages have been dropped from the buffer. Send the current
+	// state of the room, so that the player can catch up.
+	room.send(player, &GameMessage{
+		Player:   playerID,
+		Room:     roomID,
+		Event:    eventPlayerJoin,
+		Response: room.roomResponse(),
+	})
+
+	if room.inGame() {
+		room.sendDeal(player, room.turnPlayerID(), true)
+	}
+
+	return nil
+}
+
+// resendMessages sends the buffered messages sent to the player with sequence
+// numbers in the given range (exclusive of `from`). If the buffer doesn't have
+// them anymore, then the player gets the current state of the game instead.
+func (r *Room) resendMessages(p *Player, from, to uint64) {
+	if len(r.history) > 0 && r.history[0].seq > from+1 {
+		if r.inGame() {
+			r.sendDeal(p, r.turnPlayerID(), true)
+		}
+
+		return
+	}
+
+	for _, m := range r.history {
+		if m.seq > from && m.seq <= to && m.playerID == p.id {
+			p.conn.send(m.data)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientAddr returns the address of the client making the request. If the
+// hub trusts the proxy, then this is the last address in `X-Forwarded-For`
+// (which has been added by the proxy, while the others could be anything).
+func (hub *Hub) clientAddr(r *http.Request) string {
+	if values := r.Header["X-Forwarded-For"]; hub.opts.TrustProxy && len(values) > 0 {
+		forwarded := strings.Split(values[len(values)-1], ",")
+		if addr := strings.TrimSpace(forwarded[len(forwarded)-1]); addr != "" {
+			return addr
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// requestScheme returns the scheme (`http` or `https`) used by the client.
+// If the hub trusts the proxy, then this is taken from `X-Forwarded-Proto`.
+func (hub *Hub) requestScheme(r *http.Request) string {
+	if hub.opts.TrustProxy {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")
+		if proto := strings.ToLower(strings.TrimSpace(forwarded[0])); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
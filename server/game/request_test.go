@@ -0,0 +1,42 @@
+package game
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.RemoteAddr = "10.0.0.2:51234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	h := NewHub(Options{CleanupInterval: time.Hour})
+	defer h.Close()
+	assert.Equal("10.0.0.2", h.clientAddr(r))
+	assert.Equal("http", h.requestScheme(r))
+
+	h = NewHub(Options{CleanupInterval: time.Hour, TrustProxy: true})
+	defer h.Close()
+	assert.Equal("203.0.113.7", h.clientAddr(r))
+	assert.Equal("https", h.requestScheme(r))
+
+	// Clients can't pick their address by sending the header themselves.
+	r.Header.Set("X-Forwarded-For", "192.0.2.66, 203.0.113.7")
+	assert.Equal("203.0.113.7", h.clientAddr(r))
+	r.Header.Set("X-Forwarded-For", "192.0.2.66")
+	r.Header.Add("X-Forwarded-For", "203.0.113.7")
+	assert.Equal("203.0.113.7", h.clientAddr(r))
+
+	r.Header.Del("X-Forwarded-For")
+	r.Header.Set("X-Forwarded-Proto", "gopher")
+	r.TLS = &tls.ConnectionState{}
+	assert.Equal("10.0.0.2", h.clientAddr(r))
+	assert.Equal("https", h.requestScheme(r))
+}
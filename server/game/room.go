@@ -0,0 +1,1225 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+type turnEffect int
+
+const (
+	turnApplied = iota
+	turnFailed
+	tableFull
+	gameEnds
+)
+
+// Player represents a player with an active connection (over any transport).
+// A player can belong to one room at most.
+type Player struct {
+	conn connection
+	// ID of this player.
+	id string
+	// ID of the room to which this player belongs.
+	roomID string
+	// Session of the player's connection (for the language of messages from the server).
+	sess *session
+	// Whether this player wants only the changes in the game after the first deal.
+	deltas bool
+	// Last deal sent to this player (for computing the changes) and its sequence number.
+	lastDeal    *DealResponse
+	lastDealSeq uint64
+	// Player's hand containing cards.
+	hand []Card
+	// Whether this player is the dealer for some round.
+	dealer bool
+	// Index of this player (i.e., for turns).
+	index uint8
+	// Whether this player has left this room and has been disabled.
+	// If they have, then another player can take their place.
+	left bool
+	// Whether this player has exited this room after getting rid
+	// of all of their cards.
+	exited bool
+	// Whether this player is ready for the next game to begin.
+	ready bool
+	// Recent turns submitted by this player (for ignoring duplicates).
+	actions []playerAction
+}
+
+// language in which this player currently wants messages from the server.
+func (p *Player) language() string {
+	if p.sess == nil {
+		return ""
+	}
+
+	return p.sess.language()
+}
+
+// debugString for `Player`
+func (p *Player) debugString() string {
+	return spew.Sprintf("Room ID: %+v\nHand: %+v\nisDealer: %+v\nindex: %+v\nhasLeft: %+v\n",
+		p.roomID, p.hand, p.dealer, p.index, p.left)
+}
+
+// hasCard checks whether the player has the given card in their hand.
+func (p *Player) hasCard(card Card) bool {
+	for _, c := range p.hand {
+		if c.Label == card.Label && c.Suite == card.Suite {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeCard from the player's hand (returns `true` if the card gets removed).
+func (p *Player) removeCard(card Card) bool {
+	cardIdx := -1
+	for i, c := range p.hand {
+		if c.Label == card.Label && c.Suite == card.Suite {
+			cardIdx = i
+			break
+		}
+	}
+
+	if cardIdx >= 0 {
+		// Swap remove.
+		p.hand[cardIdx] = p.hand[len(p.hand)-1]
+		p.hand = p.hand[:len(p.hand)-1]
+		return true
+	}
+
+	return false
+}
+
+// containsSuite checks whether the player has a card matching the suite
+// of the given card.
+func (p Player) containsSuite(card Card) *Card {
+	for _, c := range p.hand {
+		if c.Suite == card.Suite {
+			return &c
+		}
+	}
+
+	return nil
+}
+
+// Room containing some players.
+type Room struct {
+	// Lock so that only one connection can persist stuff at a time.
+	lock sync.Mutex
+	// ID of this room.
+	id string
+	// Logger of the hub owning this room.
+	logger *log.Logger
+	// Address of the client which created this room.
+	creator string
+	// Current phase of the game in this room.
+	phase roomPhase
+	// Map of player IDs to their meta info.
+	players map[string]*Player
+	// Index of the player taking the current turn.
+	currentTurn uint8
+	// Max number of players allowed in this room.
+	limit uint8
+	// Table containing player IDs and their cards for this round.
+	table []PlayerCard
+	// The player who lost in the previous round.
+	previousAcePlayer *Player
+	// If the player who lost in the previous round loses again,
+	// then the start accumulating high rank cards. This is reset
+	// when another player loses.
+	acePlayerCollection []Card
+	// Cards which have left play after clean rounds in the current game.
+	discarded []Card
+	// Timestamp of the last performed action in this room.
+	lastUpdatedTime time.Time
+	// Timer for dealing once all players are ready (if a countdown is running).
+	countdown *time.Timer
+	// Incremented whenever a countdown begins or gets cancelled, so that
+	// a timer which has already fired can check whether it's stale.
+	countdownSeq uint
+	// Number of turns that have been played in this room. This is
+	// incremented whenever the turn moves to some player.
+	turn uint64
+	// Sequence number of the last message sent to players in this room.
+	seq uint64
+	// Recently sent messages for replaying to players who've missed them.
+	history []bufferedMessage
+}
+
+// `debugString` for `Room`.
+func (r *Room) debugString() string {
+	s := "Players:\n"
+	for id, p := range r.players {
+		s += spew.Sprintf("%#+v: %s\n", id, p.debugString())
+	}
+
+	s += spew.Sprintf("Current turn: %+v\nLimit: %+v\nTable: %+v\n",
+		r.currentTurn, r.limit, r.table)
+	return s
+}
+
+// endRound in this room by clearing the table. If a player
+// doesn't have any card in their hand, then the player is marked
+// "exited" and their ID is returned.
+func (r *Room) endRound() []string {
+	playerIDs := make([]string, 0)
+
+	for id, p := range r.players {
+		if len(p.hand) == 0 && !p.exited {
+			if r.currentTurn == p.index {
+				// We've encountered an edge case where a player has
+				// exited with a high card. Set the dealer again.
+				for i, c := range r.table {
+					if c.ID == id {
+						r.table[i].Card.Label = "" // will be skipped during checks
+						r.setDealerForNextRound()
+						break
+					}
+				}
+			}
+
+			p.exited = true
+			playerIDs = append(playerIDs, id)
+		}
+	}
+
+	r.table = make([]PlayerCard, 0)
+	return playerIDs
+}
+
+// resolvedTrick summarizes the round in the table. This should be called
+// before the table gets cleared. The dealer for the next round isn't set,
+// because it could change when the table is cleared.
+func (r *Room) resolvedTrick() *TrickResponse {
+	resp := &TrickResponse{
+		Table: make([]PlayerCard, len(r.table)),
+	}
+
+	copy(resp.Table, r.table)
+	if len(r.table) == 0 {
+		return resp
+	}
+
+	lead, last := r.table[0], r.table[len(r.table)-1]
+	resp.Leader = lead.ID
+	resp.Suite = lead.Card.Suite
+	if last.Card.Suite != lead.Card.Suite {
+		// Player who had the highest rank has been made the dealer
+		// and has picked up all the cards in the table.
+		resp.Breaker = last.ID
+		resp.PickedUpBy = r.dealerID()
+		resp.PickedUp = uint8(len(r.table))
+	}
+
+	return resp
+}
+
+// dealerID returns the ID of the dealer in this room (if any).
+func (r *Room) dealerID() string {
+	for id, p := range r.players {
+		if p.dealer {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// tableReachedLimit returns whether the table has cards from all players
+// with at least one card in their hands, indicating the end of a round.
+func (r *Room) tableReachedLimit() bool {
+	l := len(r.table)
+	for _, p := range r.players {
+		if !p.exited {
+			l--
+		}
+	}
+
+	return l == 0
+}
+
+// forgottenPlayer returns a player who has left this room at some point.
+// If an existing ID matches the new ID, then that player is returned instead.
+func (r *Room) forgottenPlayer(newID string) (string, *Player) {
+	var someID string
+	var somePlayer *Player
+	for id, p := range r.players {
+		if p.left {
+			if newID == id {
+				return id, p
+			}
+
+			someID = id
+			somePlayer = p
+		}
+	}
+
+	return someID, somePlayer
+}
+
+// setDealerForNextRound resets previous dealers, gets the player
+// who has submitted the highest ranked card and marks them as dealer.
+// Also updates the room's `currentTurn` with that player's index.
+func (r *Room) setDealerForNextRound() (string, *Player) {
+	highRank := uint8(0)
+	player := ""
+	for _, c := range r.table {
+		if c.Card.Label == "" { // opaque label for skipping
+			continue
+		}
+
+		rank := labelRanks[c.Card.Label]
+		if rank > highRank {
+			highRank = rank
+			player = c.ID
+		}
+	}
+
+	// Reset previous dealer.
+	for _, p := range r.players {
+		p.dealer = false
+	}
+
+	newDealer := r.players[player]
+	newDealer.dealer = true
+	// log.Printf("New dealer: %s (index: %d, old index: %d)\n",
+	// 	player, newDealer.index, r.currentTurn)
+	r.currentTurn = newDealer.index
+	return player, newDealer
+}
+
+// nextPlayerWithHand returns the player following the given player index
+// with cards in their hand.
+func (r *Room) nextPlayerWithHand(index uint8) *Player {
+	players := make([]string, len(r.players))
+	for id, p := range r.players {
+		players[p.index] = id
+	}
+
+	next := index + 1
+	for {
+		if next == uint8(len(r.players)) {
+			next = 0 // wrap around
+		}
+
+		if next == index {
+			return nil // no one's there
+		}
+
+		player := r.players[players[next]]
+		if len(player.hand) > 0 {
+			// log.Printf("Next player with hand: %s (index: %d)\n", players[next], player.index)
+			return player
+		}
+
+		next++
+	}
+}
+
+// addCardToTable for the given player and increment the turn.
+// Also dispose the pile if necessary.
+func (r *Room) addCardToTable(playerID string, player *Player, card Card) bool {
+	r.table = append(r.table, PlayerCard{
+		ID:   playerID,
+		Card: card,
+	})
+
+	nextPlayer := r.nextPlayerWithHand(player.index)
+	if nextPlayer == nil {
+		return false
+	}
+
+	// log.Printf("Switching to player %d\n", nextPlayer.index+1)
+	r.currentTurn = nextPlayer.index
+	return true
+}
+
+// isFull checks whether this room is full.
+func (r *Room) isFull() bool {
+	return len(r.players) == int(r.limit)
+}
+
+// playerIDs returns the IDs of players in this room
+// in the order they'd joined.
+func (r *Room) playerIDs() []string {
+	players := make([]string, len(r.players))
+	for k, p := range r.players {
+		players[p.index] = k
+	}
+
+	return players
+}
+
+// winnerIDs indicate players who have successfully gotten rid
+// of all their cards.
+func (r *Room) winnerIDs() []string {
+	players := make([]string, 0)
+	for k, p := range r.players {
+		if p.exited {
+			players = append(players, k)
+		}
+	}
+
+	return players
+}
+
+// matchesSuite checks whether all cards in the table matches
+// the given card's suite.
+func (r *Room) matchesSuite(card Card) bool {
+	matches := true
+	for _, c := range r.table {
+		matches = matches && c.Card.Suite == card.Suite
+	}
+
+	return matches
+}
+
+// allReady checks whether this room is full and all its players are ready.
+func (r *Room) allReady() bool {
+	if !r.isFull() {
+		return false
+	}
+
+	for _, p := range r.players {
+		if !p.ready {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readyIDs returns the IDs of players who are ready for the next game.
+func (r *Room) readyIDs() []string {
+	players := make([]string, 0)
+	for k, p := range r.players {
+		if p.ready {
+			players = append(players, k)
+		}
+	}
+
+	return players
+}
+
+// roomResponse for broadcasting the state of this room to its players.
+func (r *Room) roomResponse() *RoomResponse {
+	return &RoomResponse{
+		Players: r.playerIDs(),
+		Escaped: r.winnerIDs(),
+		Max:     r.limit,
+		TurnIdx: r.currentTurn,
+		Ready:   r.readyIDs(),
+	}
+}
+
+// updateCountdown begins the countdown for dealing if all players are ready,
+// or cancels an existing countdown if that's no longer the case. Once the
+// countdown finishes, a new game is started and the players are dealt.
+//
+// **NOTE:** The caller is responsible for synchronizing access to room pointer.
+func (r *Room) updateCountdown() {
+	ready := r.allReady()
+	if ready && r.phase == phaseLobby {
+		if !r.changePhase(phaseDealing) {
+			return
+		}
+	} else if !ready && r.phase == phaseDealing {
+		if !r.changePhase(phaseLobby) {
+			return
+		}
+	} else {
+		return // nothing has changed
+	}
+
+	r.countdownSeq++
+	seconds := uint8(0)
+	if ready {
+		seq := r.countdownSeq
+		seconds = gameCountdownSeconds
+		r.countdown = time.AfterFunc(gameCountdownSeconds*time.Second, func() {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+
+			if seq != r.countdownSeq {
+				return // cancelled while we were waiting on the lock.
+			}
+
+			r.countdown = nil
+			if !r.changePhase(phaseInTrick) {
+				return
+			}
+
+			r.lastUpdatedTime = time.Now()
+			r.startGame()
+			r.dealConnectedPlayers(nil)
+		})
+	} else {
+		r.countdown.Stop()
+		r.countdown = nil
+	}
+
+	for id, p := range r.players {
+		r.send(p, &GameMessage{
+			Player:   id,
+			Room:     p.roomID,
+			Event:    eventGameCountdown,
+			Response: &CountdownResponse{Seconds: seconds},
+		})
+	}
+}
+
+// send the message to the given player after stamping it with the phase of this room.
+func (r *Room) send(p *Player, msg *GameMessage) {
+	r.stamp(msg)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		r.logger.Printf("Error encoding %s message in room %s: %s\n", msg.Event, r.id, err)
+		return
+	}
+
+	r.record(p.id, msg.Seq, data)
+	p.conn.send(data)
+}
+
+// broadcastNotice sends a system message (i.e., without a player)
+// to all players in this room, in their own languages.
+func (r *Room) broadcastNotice(key msgKey, args ...interface{}) {
+	for _, p := range r.players {
+		r.send(p, &GameMessage{
+			Room:  p.roomID,
+			Event: eventPlayerMsg,
+			Msg:   translate(p.language(), key, args...),
+		})
+	}
+}
+
+// changePhase of this room and log if the transition is invalid.
+func (r *Room) changePhase(phase roomPhase) bool {
+	if err := r.setPhase(phase); err != nil {
+		r.logger.Printf("Room %s: %s\n", r.id, err)
+		return false
+	}
+
+	return true
+}
+
+// startGame clears the table, begins a new game and deals all players.
+// If this room has an ongoing game, then it finds the player
+// who hasn't "exited", and hands them high rank card(s) depending
+// on how many times they've lost.
+func (r *Room) startGame() {
+	r.turn++
+	r.table = make([]PlayerCard, 0)
+	r.discarded = make([]Card, 0)
+	aceCount := 0
+	var acePlayer *Player
+	for _, p := range r.players {
+		if !p.exited {
+			aceCount++
+			acePlayer = p
+		}
+	}
+
+	aceExistedBefore := r.previousAcePlayer != nil
+	aceExistsNow := acePlayer != nil && aceCount == 1
+	isAcePlayerNew := aceExistedBefore && aceExistsNow && r.previousAcePlayer.index != acePlayer.index
+
+	if aceExistsNow {
+		if !aceExistedBefore || isAcePlayerNew {
+			// If we have an ace and if it's either first time or it's for a different player,
+			// then reset with an ace spade.
+			r.acePlayerCollection = []Card{aceSpade}
+		} else if aceExistedBefore && !isAcePlayerNew {
+			// If it's the same player getting an ace, then whack them with another high card.
+			nextCard := getNextAceCard(r.acePlayerCollection[len(r.acePlayerCollection)-1])
+			if nextCard != nil {
+				r.acePlayerCollection = append(r.acePlayerCollection, *nextCard)
+			}
+		}
+
+		r.previousAcePlayer = acePlayer
+	}
+
+	hands := randomDeckChunks(r.limit, r.acePlayerCollection)
+	if aceExistsNow || aceExistedBefore {
+		idx := r.previousAcePlayer.index
+		// This ensures that the lost player gets the high rank card(s) again.
+		hands[0], hands[idx] = hands[idx], hands[0]
+	}
+
+	for _, p := range r.players {
+		p.exited = false
+		p.ready = false
+		p.hand = hands[p.index]
+		// If player has a spade ace, then they're the dealer.
+		for _, card := range p.hand {
+			if card.Label == aceSpade.Label && card.Suite == aceSpade.Suite {
+				r.logger.Printf("Ace cards in room %s: %s\n", p.roomID, r.acePlayerCollection)
+				p.dealer = true
+				r.currentTurn = p.index
+			}
+		}
+	}
+}
+
+// broadcastTrick to all players in this room after setting
+// the dealer for the next round.
+func (r *Room) broadcastTrick(trick *TrickResponse) {
+	trick.Dealer = r.dealerID()
+	for id, p := range r.players {
+		r.send(p, &GameMessage{
+			Player:   id,
+			Room:     p.roomID,
+			Event:    eventTrickResolved,
+			Response: trick,
+		})
+	}
+}
+
+// dealConnectedPlayers through the given WS connection.
+// This requires that `room.currentTurn` is set for the next player.
+func (r *Room) dealConnectedPlayers(conn connection) {
+	turnPlayerID := r.turnPlayerID()
+
+	// Send dealt hands to all players after setting up.
+	for _, p := range r.players {
+		r.sendDeal(p, turnPlayerID, false)
+	}
+}
+
+// turnPlayerID returns the ID of the player taking the current turn.
+func (r *Room) turnPlayerID() string {
+	for id, p := range r.players {
+		if p.index == r.currentTurn {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// dealResponse containing the view of this room for the given player.
+func (r *Room) dealResponse(playerID, turnPlayerID string) *DealResponse {
+	p := r.players[playerID]
+	opponents := make(map[string]uint8)
+	for id, o := range r.players {
+		if id != playerID {
+			opponents[id] = uint8(len(o.hand))
+		}
+	}
+
+	return &DealResponse{
+		Hand:          p.hand,
+		IsDealer:      p.dealer,
+		OurTurn:       r.currentTurn == p.index,
+		TurnPlayer:    turnPlayerID,
+		Table:         r.table,
+		OpponentHands: opponents,
+		Discarded:     r.discarded,
+		AceCards:      r.acePlayerCollection,
+		LegalCards:    r.legalCards(p),
+		Turn:          r.turn,
+	}
+}
+
+// discardTable moves the cards in the table to the discarded pile. This
+// should be called only when nobody has picked up the cards.
+func (r *Room) discardTable() {
+	for _, c := range r.table {
+		r.discarded = append(r.discarded, c.Card)
+	}
+}
+
+// validateAndApplyTurn from the given player in the given room.
+func (hub *Hub) validateAndApplyTurn(conn connection, roomID, playerID string, data *json.RawMessage) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgRoomMissingRestart,
+			args:    []interface{}{roomID},
+			Event:   eventRoomMissing,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	room.lastUpdatedTime = time.Now()
+	defer room.lock.Unlock()
+
+	player, exists := room.players[playerID]
+	if !exists {
+		return &HandlerError{
+			Code:    errNotInRoom,
+			key:     msgNotInRoom,
+			args:    []interface{}{roomID},
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	var req TurnRequest
+	if data == nil || json.Unmarshal(*data, &req) != nil {
+		return &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidTurn,
+		}
+	}
+
+	if action := player.findAction(req.ActionID); action != nil {
+		// We've seen this submission before. Don't apply it again,
+		// but let the player know what happened the first time.
+		if action.err == nil {
+			room.resendMessages(player, action.fromSeq, action.toSeq)
+		}
+
+		return action.err
+	}
+
+	fromSeq := room.seq
+	e := hub.playTurn(conn, room, playerID, &req)
+	player.rememberAction(req.ActionID, e, fromSeq, room.seq)
+	return e
+}
+
+// playTurn checks whether the player is allowed to submit the card for this turn,
+// applies it and notifies all players in the room.
+//
+// **NOTE:** The caller is responsible for synchronizing access to room pointer.
+func (hub *Hub) playTurn(conn connection, room *Room, playerID string, req *TurnRequest) *HandlerError {
+	if e := room.checkEventPhase(eventPlayerTurn); e != nil {
+		return e
+	}
+
+	// Check whether the turn has moved on since the player has submitted the card.
+	if req.Turn != 0 && req.Turn != room.turn {
+		return &HandlerError{
+			Code:    errStaleTurn,
+			key:     msgStaleTurn,
+			Details: &TurnDetails{Turn: room.turn},
+		}
+	}
+
+	// Check whether this is the player's turn.
+	if room.players[playerID].index != room.currentTurn {
+		return &HandlerError{
+			Code: errNotYourTurn,
+			key:  msgNotYourTurn,
+		}
+	}
+
+	turnEffect, e := hub.applyPlayerTurn(room, playerID, req.Card)
+	if e != nil {
+		return e
+	}
+
+	var trick *TrickResponse
+	if turnEffect == tableFull || turnEffect == gameEnds {
+		room.changePhase(phaseTrickResolved)
+		trick = room.resolvedTrick()
+	}
+
+	if turnEffect == tableFull {
+		// Notify players before clearing the table.
+		room.dealConnectedPlayers(conn)
+		// log.Println("Table reached limit. Setting dealer for next round.")
+		if trick.Breaker == "" {
+			room.discardTable()
+		}
+
+		winnerIDs := room.endRound()
+		room.broadcastTrick(trick)
+
+		// Broadcast winning message to all players at the end of a round.
+		if len(winnerIDs) > 0 {
+			for _, winnerID := range winnerIDs {
+				for _, p := range room.players {
+					room.send(p, &GameMessage{
+						Player: winnerID,
+						Room:   p.roomID,
+						Event:  eventPlayerWins,
+					})
+				}
+			}
+		}
+
+		// By the end of each round, check if the game has ended.
+		if room.nextPlayerWithHand(room.currentTurn) == nil {
+			turnEffect = gameEnds
+		}
+	} else if turnEffect == gameEnds {
+		// Game has ended before the table could be cleared.
+		room.broadcastTrick(trick)
+	}
+
+	if turnEffect == gameEnds {
+		// Players should get ready for the next game.
+		room.changePhase(phaseGameOver)
+	} else if turnEffect == tableFull {
+		room.changePhase(phaseInTrick)
+	}
+
+	room.dealConnectedPlayers(conn)
+
+	// If game has ended, broadcast victim's losing to all players.
+	if turnEffect == gameEnds {
+		// There could be multiple winners, in which case, `victimID` would be an empty string.
+		var victimID string
+		for id, p := range room.players {
+			if len(p.hand) > 0 {
+				victimID = id
+			} else {
+				// Set the exit status of players without any cards. This is an off-by-one
+				// case which happens when the last turn involves a player dumping their
+				// last card to their opponent and winning the game.
+				p.exited = true
+			}
+		}
+
+		for _, p := range room.players {
+			room.send(p, &GameMessage{
+				Player: victimID,
+				Room:   p.roomID,
+				Event:  eventGameOver,
+			})
+		}
+	}
+
+	return nil
+}
+
+// validateCard checks whether the given player is allowed to submit the card
+// to the table. This doesn't check whether it's the player's turn.
+func (r *Room) validateCard(player *Player, card Card) *HandlerError {
+	if !player.hasCard(card) {
+		return &HandlerError{
+			Code:    errCardMissing,
+			key:     msgCardMissing,
+			Details: &CardDetails{Card: card},
+		}
+	}
+
+	if len(r.table) == 0 {
+		// Table is empty. If the player isn't the dealer, reject the request.
+		if !player.dealer {
+			return &HandlerError{
+				Code: errNotDealer,
+				key:  msgNotDealer,
+			}
+		}
+	} else if !r.matchesSuite(card) {
+		// No match! If the player has that suite and is making an illegal move,
+		// reject that request.
+		matchedCard := player.containsSuite(r.table[0].Card)
+		if matchedCard != nil {
+			return &HandlerError{
+				Code:    errIllegalMove,
+				key:     msgIllegalMove,
+				args:    []interface{}{matchedCard.Label, prettyMap[matchedCard.Suite]},
+				Details: &CardDetails{Card: *matchedCard},
+			}
+		}
+	}
+
+	return nil
+}
+
+// legalCards returns the cards which the given player is allowed to submit
+// to the table. This is empty if it's not the player's turn.
+func (r *Room) legalCards(player *Player) []Card {
+	cards := make([]Card, 0)
+	if r.phase != phaseInTrick || player.index != r.currentTurn {
+		return cards
+	}
+
+	for _, c := range player.hand {
+		if r.validateCard(player, c) == nil {
+			cards = append(cards, c)
+		}
+	}
+
+	return cards
+}
+
+// applyPlayerTurn (after validation) in the given room using the player and their card.
+//
+// **NOTE:** The caller is responsible for synchronizing access to room pointer.
+func (hub *Hub) applyPlayerTurn(room *Room, playerID string, card Card) (turnEffect, *HandlerError) {
+	// log.Printf("Before update: %s", room.debugString())
+	player := room.players[playerID]
+	if e := room.validateCard(player, card); e != nil {
+		return turnFailed, e
+	}
+
+	// Card is valid. Remove it from the player's hand and let's rank stuff.
+	player.removeCard(card)
+	room.turn++
+	if len(room.table) == 0 {
+		if !room.addCardToTable(playerID, player, card) {
+			return gameEnds, nil
+		}
+	} else if room.matchesSuite(card) {
+		// Card matches the suites in table.
+		if !room.addCardToTable(playerID, player, card) {
+			return gameEnds, nil
+		}
+
+		// If table has reached its limit, then we can set the dealer and
+		// begin the next round.
+		if room.tableReachedLimit() {
+			room.setDealerForNextRound()
+			return tableFull, nil
+		}
+	} else {
+		// No match! Player who had the highest rank gets all the junk
+		// and becomes the dealer.
+		_, newDealer := room.setDealerForNextRound()
+		// log.Printf("New dealer: %s -> %s", dealerID, newDealer.debugString())
+		newDealer.hand = append(newDealer.hand, card)
+		for _, c := range room.table {
+			newDealer.hand = append(newDealer.hand, c.Card)
+		}
+
+		// Temporarily add the card to table. Table will be cleared by the caller later.
+		room.table = append(room.table, PlayerCard{
+			ID:   playerID,
+			Card: card,
+		})
+
+		if room.nextPlayerWithHand(newDealer.index) == nil {
+			return gameEnds, nil
+		}
+
+		return tableFull, nil
+	}
+
+	// log.Printf("After update: %s", room.debugString())
+	return turnApplied, nil
+}
+
+// Adds player to a room. The room must exist at this point. Also does some sanity
+// checks to ensure that some player cannot override someone else's stuff.
+func (hub *Hub) addPlayer(conn connection, roomID, playerID string, sess *session) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgRoomMissingCreate,
+			args:    []interface{}{roomID},
+			Event:   eventRoomMissing,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	defer room.lock.Unlock()
+
+	return hub.addPlayerToUnlockedRoom(conn, room, roomID, playerID, sess)
+}
+
+// addPlayerToUnlockedRoom accepts an unlocked room and does whatever `addPlayer` method says.
+// The method has been split so as to avoid a possible race condition.
+func (hub *Hub) addPlayerToUnlockedRoom(conn connection, room *Room, roomID, playerID string, sess *session) *HandlerError {
+	room.lastUpdatedTime = time.Now()
+	swapPlayer := ""
+
+	if room.isFull() {
+		oldID, oldPlayer := room.forgottenPlayer(playerID)
+		if oldPlayer == nil {
+			return &HandlerError{
+				Code:    errRoomFull,
+				key:     msgRoomFull,
+				args:    []interface{}{roomID},
+				Event:   eventRoomExists,
+				Details: &RoomDetails{Room: roomID},
+			}
+		}
+
+		// This player can take the place of an old player.
+		swapPlayer = oldID
+	}
+
+	_, exists := room.players[playerID]
+	if exists && swapPlayer == "" {
+		return &HandlerError{
+			Code:    errPlayerExists,
+			key:     msgPlayerExists,
+			args:    []interface{}{playerID, roomID},
+			Event:   eventPlayerExists,
+			Details: &PlayerDetails{Room: roomID, Player: playerID},
+		}
+	}
+
+	_, features := sess.negotiated()
+	player := &Player{
+		conn:   conn,
+		id:     playerID,
+		roomID: roomID,
+		sess:   sess,
+		deltas: hasFeature(features, "deltas"),
+		hand:   make([]Card, 0),
+		index:  uint8(len(room.players)),
+	}
+
+	if swapPlayer != "" {
+		hub.opts.Logger.Printf("Swapping player %s with %s\n", swapPlayer, playerID)
+		oldPlayer := room.players[swapPlayer]
+		player.hand = oldPlayer.hand
+		player.dealer = oldPlayer.dealer
+		player.index = oldPlayer.index
+		player.exited = oldPlayer.exited
+		// NOTE: Ignore `left` field.
+		delete(room.players, swapPlayer)
+	}
+
+	room.players[playerID] = player
+	hub.setConnection(conn, roomID)
+	for _, p := range room.players {
+		room.send(p, &GameMessage{
+			Player:   playerID,
+			Room:     roomID,
+			Event:    eventPlayerJoin,
+			Response: room.roomResponse(),
+		})
+	}
+
+	room.broadcastNotice(msgPlayerJoined, playerID)
+	if swapPlayer != "" && room.inGame() {
+		room.dealConnectedPlayers(conn)
+	} else if room.isFull() {
+		hub.opts.Logger.Printf("Room %s is full. Waiting for players to get ready.\n", roomID)
+	}
+
+	return nil
+}
+
+// Creates a room with the given data and adds the player to that room.
+func (hub *Hub) createRoomWithPlayer(conn connection, roomID, playerID string, sess *session, data *json.RawMessage) *HandlerError {
+	for {
+		room, exists := hub.getRoom(roomID)
+		if roomID == "" {
+			roomID = randSeq(16)
+			continue
+		} else if exists {
+			room.lock.Lock()
+			defer room.lock.Unlock()
+
+			if room.isFull() {
+				_, oldPlayer := room.forgottenPlayer(playerID)
+				if oldPlayer == nil {
+					return &HandlerError{
+						Code:    errRoomFull,
+						key:     msgRoomExistsFull,
+						args:    []interface{}{roomID},
+						Event:   eventRoomExists,
+						Details: &RoomDetails{Room: roomID},
+					}
+				}
+			}
+
+			return hub.addPlayerToUnlockedRoom(conn, room, roomID, playerID, sess)
+		} else {
+			break
+		}
+	}
+
+	var req RoomCreationRequest
+	if data == nil || json.Unmarshal(*data, &req) != nil {
+		return &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidRoomCreation,
+		}
+	}
+
+	opts := hub.settings()
+	min, max := opts.MinPlayers, opts.MaxPlayers
+	if req.Players < min || req.Players > max {
+		return &HandlerError{
+			Code:    errPlayerLimit,
+			key:     msgPlayerLimit,
+			args:    []interface{}{min, max},
+			Details: &PlayerLimitDetails{Min: min, Max: max},
+		}
+	}
+
+	room := &Room{
+		id:                  roomID,
+		logger:              hub.opts.Logger,
+		creator:             sess.addr,
+		phase:               phaseLobby,
+		players:             make(map[string]*Player),
+		limit:               req.Players,
+		table:               make([]PlayerCard, 0),
+		acePlayerCollection: make([]Card, 0),
+		discarded:           make([]Card, 0),
+		lastUpdatedTime:     time.Now(),
+	}
+
+	if e := hub.reserveRoom(conn, roomID, playerID, sess, data); e != nil {
+		return e
+	}
+
+	if hub.addRoom(roomID, room) != room {
+		// Someone else has created the room in the meantime, so we join
+		// their room instead.
+		hub.releaseRoom(room)
+		return hub.createRoomWithPlayer(conn, roomID, playerID, sess, data)
+	}
+
+	room.lock.Lock()
+	defer room.lock.Unlock()
+
+	return hub.addPlayerToUnlockedRoom(conn, room, roomID, playerID, sess)
+}
+
+// shareMessage from one player to everyone in the room (including the player).
+func (hub *Hub) shareMessage(conn connection, roomID, playerID, msg string) *HandlerError {
+	if msg == "" {
+		return &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidRequest,
+		}
+	}
+
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgInvalidRoom,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	room.lastUpdatedTime = time.Now()
+	defer room.lock.Unlock()
+
+	if _, exists := room.players[playerID]; !exists {
+		return &HandlerError{
+			Code:    errNotInRoom,
+			key:     msgNotInRoom,
+			args:    []interface{}{roomID},
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	for _, p := range room.players {
+		room.send(p, &GameMessage{
+			Player: playerID,
+			Room:   roomID,
+			Event:  eventPlayerMsg,
+			Msg:    msg,
+		})
+	}
+
+	return nil
+}
+
+// playerRequestedNewGame ends the ongoing game and takes the room back to
+// the lobby, where everyone has to get ready again for the next game.
+func (hub *Hub) playerRequestedNewGame(conn connection, roomID, playerID string) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgInvalidRoom,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	room.lastUpdatedTime = time.Now()
+	defer room.lock.Unlock()
+
+	if _, exists := room.players[playerID]; !exists {
+		return &HandlerError{
+			Code: errNotAllowed,
+			key:  msgNotAllowed,
+		}
+	}
+
+	if e := room.checkEventPhase(eventNewGameRequest); e != nil {
+		return e
+	}
+
+	hub.opts.Logger.Printf("Player %s has ended the game in room %s.", playerID, roomID)
+	room.changePhase(phaseLobby)
+	room.table = make([]PlayerCard, 0)
+	for _, p := range room.players {
+		p.ready = false
+	}
+
+	for _, p := range room.players {
+		room.send(p, &GameMessage{
+			Player: playerID,
+			Room:   roomID,
+			Event:  eventGameRestart,
+		})
+	}
+
+	return nil
+}
+
+// setPlayerReady toggles the readiness of a player in the lobby, broadcasts it
+// to all players and begins the countdown for dealing once everyone is ready.
+func (hub *Hub) setPlayerReady(conn connection, roomID, playerID string, data *json.RawMessage) *HandlerError {
+	room, exists := hub.getRoom(roomID)
+	if !exists {
+		return &HandlerError{
+			Code:    errRoomMissing,
+			key:     msgRoomMissingCreate,
+			args:    []interface{}{roomID},
+			Event:   eventRoomMissing,
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	room.lock.Lock()
+	room.lastUpdatedTime = time.Now()
+	defer room.lock.Unlock()
+
+	player, exists := room.players[playerID]
+	if !exists {
+		return &HandlerError{
+			Code:    errNotInRoom,
+			key:     msgNotInRoom,
+			args:    []interface{}{roomID},
+			Details: &RoomDetails{Room: roomID},
+		}
+	}
+
+	if e := room.checkEventPhase(eventPlayerReady); e != nil {
+		return e
+	}
+
+	var req ReadyRequest
+	if data == nil || json.Unmarshal(*data, &req) != nil {
+		return &HandlerError{
+			Code: errInvalidRequest,
+			key:  msgInvalidReady,
+		}
+	}
+
+	if room.phase == phaseGameOver {
+		room.changePhase(phaseLobby)
+	}
+
+	player.ready = req.Ready
+	for _, p := range room.players {
+		room.send(p, &GameMessage{
+			Player:   playerID,
+			Room:     roomID,
+			Event:    eventPlayerReady,
+			Response: room.roomResponse(),
+		})
+	}
+
+	room.updateCountdown()
+	return nil
+}
@@ -1,12 +1,12 @@
-package main
+package game
 
 import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/net/websocket"
 )
 
 func TestPlayerGettingDumped(t *testing.T) {
@@ -18,6 +18,7 @@ func TestPlayerGettingDumped(t *testing.T) {
 	}
 
 	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
 	room.players["player1"].dealer = true
 
 	p0 := room.players["player1"]
@@ -53,6 +54,14 @@ func TestPlayerGettingDumped(t *testing.T) {
 	assert.NotContains(p1.hand, secondCard)
 	assert.Len(p1.hand, 10)
 	assert.EqualValues(room.currentTurn, 0) // first player becomes dealer.
+
+	trick := room.resolvedTrick()
+	assert.Equal("player1", trick.Leader)
+	assert.Equal("s", trick.Suite)
+	assert.Equal("player2", trick.Breaker)
+	assert.Equal("player1", trick.PickedUpBy)
+	assert.EqualValues(2, trick.PickedUp)
+	assert.Len(trick.Table, 2)
 }
 
 func TestRepetitiveDumps(t *testing.T) {
@@ -64,6 +73,7 @@ func TestRepetitiveDumps(t *testing.T) {
 	}
 
 	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
 	room.currentTurn = 2
 	room.players["player3"].dealer = true
 
@@ -130,6 +140,7 @@ func TestOnePlayerExiting(t *testing.T) {
 	}
 
 	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
 	room.currentTurn = 1
 	room.players["player2"].dealer = true
 
@@ -144,6 +155,14 @@ func TestOnePlayerExiting(t *testing.T) {
 		assert.Nil(err)
 		if i == 2 {
 			assert.EqualValues(effect, tableFull)
+			trick := room.resolvedTrick()
+			assert.Equal("player2", trick.Leader)
+			assert.Equal("h", trick.Suite)
+			assert.Empty(trick.Breaker)
+			assert.Empty(trick.PickedUpBy)
+			assert.Zero(trick.PickedUp)
+			assert.Equal("player2", room.dealerID())
+			room.discardTable()
 			winner := room.endRound()
 			assert.Equal(winner, []string{"player3"})
 		} else {
@@ -155,6 +174,13 @@ func TestOnePlayerExiting(t *testing.T) {
 	assert.Empty(p3.hand)
 	assert.Empty(room.table)
 	assert.True(p3.exited)
+
+	view := room.dealResponse("player1", "player2")
+	assert.Len(view.Hand, 7)
+	assert.Equal(map[string]uint8{"player2": 6, "player3": 0}, view.OpponentHands)
+	assert.ElementsMatch([]Card{Card{"9", "h"}, Card{"6", "h"}, Card{"8", "h"}}, view.Discarded)
+	assert.Equal("player2", view.TurnPlayer)
+	assert.False(view.OurTurn)
 }
 
 func TestOnePlayerExitWithHighCard(t *testing.T) {
@@ -166,6 +192,7 @@ func TestOnePlayerExitWithHighCard(t *testing.T) {
 	}
 
 	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
 	room.currentTurn = 1
 	room.players["player2"].dealer = true
 
@@ -204,6 +231,7 @@ func TestOnePlayerCaughtWhileExiting(t *testing.T) {
 	}
 
 	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
 	room.currentTurn = 1
 	room.players["player2"].dealer = true
 
@@ -242,6 +270,7 @@ func TestOnePlayerExited(t *testing.T) {
 	}
 
 	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
 	room.currentTurn = 1
 	room.players["player2"].dealer = true
 	room.players["player3"].exited = true
@@ -270,7 +299,8 @@ func TestOnePlayerExited(t *testing.T) {
 
 func TestGameAceCards(t *testing.T) {
 	assert := assert.New(t)
-	room, _ := setup3PlayerRoom([]string{"[]", "[]", "[{\"label\":\"5\",\"suite\":\"s\"}]"})
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[{\"label\":\"5\",\"suite\":\"s\"}]"})
+	defer h.Close()
 
 	assert.Nil(room.previousAcePlayer)
 	assert.Empty(room.acePlayerCollection)
@@ -321,8 +351,322 @@ func TestGameAceCards(t *testing.T) {
 	assert.True(p1.dealer)
 }
 
+func TestPlayersReady(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[]"})
+	defer h.Close()
+
+	assert.False(room.allReady())
+	assert.Empty(room.readyIDs())
+
+	room.players["player1"].ready = true
+	room.players["player3"].ready = true
+	assert.False(room.allReady())
+	assert.ElementsMatch([]string{"player1", "player3"}, room.readyIDs())
+
+	room.players["player2"].ready = true
+	assert.True(room.allReady())
+
+	// Starting the game resets readiness for the next one.
+	room.startGame()
+	assert.False(room.allReady())
+	assert.Empty(room.readyIDs())
+
+	// Seats need to be filled before anyone can be considered ready.
+	delete(room.players, "player3")
+	room.players["player1"].ready = true
+	room.players["player2"].ready = true
+	assert.False(room.allReady())
+}
+
+func TestNewGameRequest(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[]"})
+	defer h.Close()
+
+	// There's no game to end in the lobby.
+	assert.NotNil(h.playerRequestedNewGame(nil, "test", "player1"))
+
+	room.phase = phaseInTrick
+	room.players["player2"].ready = true
+	assert.NotNil(h.playerRequestedNewGame(nil, "test", "player4"))
+	assert.Nil(h.playerRequestedNewGame(nil, "test", "player1"))
+
+	// A single request takes everyone back to the lobby to get ready again.
+	assert.Equal(phaseLobby, room.phase)
+	assert.Empty(room.readyIDs())
+	for _, p := range room.players {
+		msgs := p.conn.(*memConnection).take()
+		if assert.NotEmpty(msgs) {
+			assert.Equal(eventGameRestart, msgs[len(msgs)-1].Event)
+			assert.Equal("player1", msgs[len(msgs)-1].Player)
+		}
+	}
+}
+
+func TestRejectedJoin(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]"})
+	defer h.Close()
+
+	// Rejected joins don't leave the connection behind in the room.
+	conn := &memConnection{}
+	sess := &session{encoding: encodingJSON}
+	room.lock.Lock()
+	e := h.addPlayerToUnlockedRoom(conn, room, "test", "player1", sess)
+	room.lock.Unlock()
+	assert.Equal(errPlayerExists, e.Code)
+	_, exists := h.deleteConnection(conn)
+	assert.False(exists)
+
+	assert.Nil(h.addPlayer(conn, "test", "player3", sess))
+	roomID, exists := h.deleteConnection(conn)
+	assert.True(exists)
+	assert.Equal("test", roomID)
+
+	// Dropping a player whose room is gone is a no-op.
+	h.setConnection(conn, "gone")
+	h.dropPlayer(conn, "player3")
+}
+
+func TestLegalCards(t *testing.T) {
+	assert := assert.New(t)
+	hands := []string{
+		"[{\"label\":\"6\",\"suite\":\"s\"},{\"label\":\"2\",\"suite\":\"h\"}]",
+		"[{\"label\":\"3\",\"suite\":\"s\"},{\"label\":\"K\",\"suite\":\"d\"},{\"label\":\"J\",\"suite\":\"s\"}]",
+		"[{\"label\":\"Q\",\"suite\":\"h\"}]",
+	}
+
+	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
+	p1, p2, p3 := room.players["player1"], room.players["player2"], room.players["player3"]
+	p1.dealer = true
+
+	// Nobody can play in the lobby.
+	assert.Empty(room.legalCards(p1))
+
+	room.phase = phaseInTrick
+	assert.ElementsMatch(p1.hand, room.legalCards(p1))
+	assert.Empty(room.legalCards(p2))
+
+	_, err := h.applyPlayerTurn(room, "player1", Card{"6", "s"})
+	assert.Nil(err)
+	assert.Empty(room.legalCards(p1))
+	assert.ElementsMatch([]Card{Card{"3", "s"}, Card{"J", "s"}}, room.legalCards(p2))
+
+	// Illegal moves don't affect the player's hand.
+	_, err = h.applyPlayerTurn(room, "player2", Card{"K", "d"})
+	assert.Equal(errIllegalMove, err.Code)
+	assert.Equal(&CardDetails{Card: Card{"3", "s"}}, err.Details)
+	assert.Len(p2.hand, 3)
+
+	_, err = h.applyPlayerTurn(room, "player2", Card{"A", "d"})
+	assert.Equal(errCardMissing, err.Code)
+
+	_, err = h.applyPlayerTurn(room, "player2", Card{"J", "s"})
+	assert.Nil(err)
+	assert.Equal([]Card{Card{"Q", "h"}}, room.legalCards(p3))
+}
+
+func TestRoomPhases(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[]"})
+	defer h.Close()
+
+	assert.Nil(room.checkEventPhase(eventPlayerReady))
+	assert.Nil(room.checkEventPhase(eventPlayerMsg))
+	e := room.checkEventPhase(eventPlayerTurn)
+	assert.NotNil(e)
+	assert.Equal(eventInvalidPhase, e.Event)
+
+	// Can't skip the countdown.
+	assert.NotNil(room.setPhase(phaseInTrick))
+	assert.Equal(phaseLobby, room.phase)
+
+	phases := []roomPhase{
+		phaseDealing, phaseInTrick, phaseTrickResolved, phaseInTrick,
+		phaseTrickResolved, phaseGameOver, phaseLobby,
+	}
+	for _, p := range phases {
+		assert.Nil(room.setPhase(p))
+		assert.Equal(p, room.phase)
+		if p == phaseInTrick {
+			assert.True(room.inGame())
+			assert.Nil(room.checkEventPhase(eventPlayerTurn))
+			assert.NotNil(room.checkEventPhase(eventPlayerReady))
+		}
+	}
+
+	assert.False(room.inGame())
+	assert.NotNil(room.setPhase(phaseGameOver))
+}
+
+func TestReplayBuffer(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[]"})
+	defer h.Close()
+
+	for i := 0; i < replayBufferSize; i++ {
+		msg := &GameMessage{Event: eventPlayerMsg}
+		room.stamp(msg)
+		assert.EqualValues(i+1, msg.Seq)
+		room.record(fmt.Sprintf("player%d", i%3+1), msg.Seq, []byte(fmt.Sprintf("%d", msg.Seq)))
+	}
+
+	messages, complete := room.messagesSince("player2", 0)
+	assert.True(complete)
+	assert.Len(messages, replayBufferSize/3)
+	assert.Equal([]byte("2"), messages[0])
+
+	messages, complete = room.messagesSince("player1", replayBufferSize-3)
+	assert.True(complete)
+	assert.Equal([][]byte{[]byte(fmt.Sprintf("%d", replayBufferSize))}, messages)
+
+	// Oldest messages get dropped once the buffer is full.
+	room.record("player1", replayBufferSize+1, []byte("last"))
+	assert.Len(room.history, replayBufferSize)
+	assert.EqualValues(2, room.history[0].seq)
+
+	_, complete = room.messagesSince("player1", 0)
+	assert.False(complete)
+	messages, complete = room.messagesSince("player1", 1)
+	assert.True(complete)
+	assert.Equal([]byte("last"), messages[len(messages)-1])
+}
+
+func TestDuplicateActions(t *testing.T) {
+	assert := assert.New(t)
+	hands := []string{
+		"[{\"label\":\"6\",\"suite\":\"s\"}]",
+		"[{\"label\":\"3\",\"suite\":\"s\"}]",
+		"[{\"label\":\"Q\",\"suite\":\"h\"}]",
+	}
+
+	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
+	p1 := room.players["player1"]
+	p1.dealer = true
+	room.phase = phaseInTrick
+	room.turn = 5
+
+	// Cards submitted for earlier turns are rejected.
+	e := h.playTurn(nil, room, "player1", &TurnRequest{Card: Card{"6", "s"}, Turn: 4})
+	assert.Equal(errStaleTurn, e.Code)
+	assert.Equal(&TurnDetails{Turn: 5}, e.Details)
+	assert.Len(p1.hand, 1)
+
+	_, e = h.applyPlayerTurn(room, "player1", Card{"6", "s"})
+	assert.Nil(e)
+	assert.EqualValues(6, room.turn)
+
+	assert.Nil(p1.findAction(""))
+	assert.Nil(p1.findAction("a1"))
+	p1.rememberAction("", nil, 0, 1)
+	assert.Empty(p1.actions)
+
+	p1.rememberAction("a1", nil, 3, 7)
+	action := p1.findAction("a1")
+	assert.NotNil(action)
+	assert.Nil(action.err)
+	assert.EqualValues(3, action.fromSeq)
+	assert.EqualValues(7, action.toSeq)
+
+	// Oldest actions are forgotten once we've reached the limit.
+	for i := 0; i < maxRememberedActions; i++ {
+		p1.rememberAction(fmt.Sprintf("b%d", i), &HandlerError{Code: errNotYourTurn}, 7, 7)
+	}
+
+	assert.Len(p1.actions, maxRememberedActions)
+	assert.Nil(p1.findAction("a1"))
+	assert.Equal(errNotYourTurn, p1.findAction("b0").err.Code)
+}
+
+func TestDuplicateTurnSubmissions(t *testing.T) {
+	assert := assert.New(t)
+	hands := []string{
+		"[{\"label\":\"6\",\"suite\":\"s\"},{\"label\":\"2\",\"suite\":\"s\"}]",
+		"[{\"label\":\"3\",\"suite\":\"s\"},{\"label\":\"4\",\"suite\":\"s\"}]",
+		"[{\"label\":\"Q\",\"suite\":\"s\"},{\"label\":\"5\",\"suite\":\"s\"}]",
+	}
+
+	room, h := setup3PlayerRoom(hands)
+	defer h.Close()
+	p1, p2 := room.players["player1"], room.players["player2"]
+	c1, c2 := p1.conn.(*memConnection), p2.conn.(*memConnection)
+	p1.dealer = true
+	room.phase = phaseInTrick
+
+	data := json.RawMessage(`{"card":{"label":"6","suite":"s"},"actionId":"a1","turn":0}`)
+	assert.Nil(h.validateAndApplyTurn(c1, "test", "player1", &data))
+	first := c1.take()
+	assert.Len(first, 1)
+	assert.Equal(eventPlayerTurn, first[0].Event)
+	assert.Len(c2.take(), 1)
+	assert.Len(p1.hand, 1)
+
+	// Retrying gives the same messages without playing the card again.
+	assert.Nil(h.validateAndApplyTurn(c1, "test", "player1", &data))
+	assert.Equal(first, c1.take())
+	assert.Empty(c2.take())
+	assert.Len(p1.hand, 1)
+
+	// Errors are remembered too.
+	data = json.RawMessage(`{"card":{"label":"Q","suite":"s"},"actionId":"b1"}`)
+	e := h.validateAndApplyTurn(c2, "test", "player2", &data)
+	assert.Equal(errCardMissing, e.Code)
+	p2.hand = append(p2.hand, Card{"Q", "s"})
+	assert.Equal(e, h.validateAndApplyTurn(c2, "test", "player2", &data))
+}
+
+func TestMessageAcks(t *testing.T) {
+	assert := assert.New(t)
+	room, h := setup3PlayerRoom([]string{"[]", "[]", "[]"})
+	defer h.Close()
+	conn := room.players["player1"].conn.(*memConnection)
+	sess := &session{encoding: encodingJSON, features: []string{"acks"}}
+
+	// Messages which weren't shared aren't acknowledged.
+	h.handle(conn, sess, &GameMessage{Event: eventPlayerMsg, Player: "player1", Room: "attic", Msg: "hi", RequestID: "1"})
+	sent := conn.take()
+	assert.Len(sent, 1)
+	assert.Equal(errRoomMissing, sent[0].Error.Code)
+
+	h.handle(conn, sess, &GameMessage{Event: eventPlayerMsg, Player: "stranger", Room: "test", Msg: "hi", RequestID: "2"})
+	sent = conn.take()
+	assert.Len(sent, 1)
+	assert.Equal(errNotInRoom, sent[0].Error.Code)
+
+	h.handle(conn, sess, &GameMessage{Event: eventPlayerMsg, Player: "player1", Room: "test", Msg: "hi", RequestID: "3"})
+	sent = conn.take()
+	assert.Len(sent, 2)
+	assert.Equal(eventPlayerMsg, sent[0].Event)
+	assert.Equal(eventAck, sent[1].Event)
+	assert.Equal("3", sent[1].RequestID)
+}
+
+func TestCloseHub(t *testing.T) {
+	h := NewHub(Options{CleanupInterval: time.Millisecond})
+	h.Close()
+	h.Close()
+
+	// All watchers should return once the hub has been closed.
+	stopped := make(chan struct{})
+	go func() {
+		h.watchers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub's goroutines are still running after closing it")
+	}
+}
+
 func setup3PlayerRoom(hands []string) (*Room, *Hub) {
 	room := &Room{
+		id:      "test",
+		phase:   phaseLobby,
 		players: map[string]*Player{},
 		limit:   3,
 		table:   make([]PlayerCard, 0),
@@ -330,21 +674,20 @@ func setup3PlayerRoom(hands []string) (*Room, *Hub) {
 
 	for i, h := range hands {
 		player := &Player{
+			conn:   &memConnection{},
+			id:     fmt.Sprintf("player%d", i+1),
 			roomID: "test",
 			hand:   make([]Card, 0),
 			index:  uint8(i),
 		}
 
 		json.Unmarshal([]byte(h), &player.hand)
-		room.players[fmt.Sprintf("player%d", i+1)] = player
+		room.players[player.id] = player
 	}
 
-	h := &Hub{
-		rooms: map[string]*Room{
-			"test": room,
-		},
-		connRooms: map[*websocket.Conn]string{},
-	}
+	h := NewHub(Options{CleanupInterval: time.Hour})
+	room.logger = h.opts.Logger
+	h.setRoom("test", room)
 
 	return room, h
 }
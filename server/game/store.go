@@ -0,0 +1,43 @@
+package game
+
+// Store keeps the rooms of some hub. Hubs access their store from a single
+// goroutine, so implementations needn't be safe for concurrent use.
+type Store interface {
+	// Get the room for the given ID (or `nil` if it doesn't exist).
+	Get(id string) *Room
+	// Set the room for the given ID.
+	Set(id string, room *Room)
+	// Delete the room for the given ID.
+	Delete(id string)
+	// IDs of all rooms in the store.
+	IDs() []string
+}
+
+// memoryStore keeps rooms in a map (default).
+type memoryStore map[string]*Room
+
+// NewMemoryStore returns a store which keeps rooms in memory.
+func NewMemoryStore() Store {
+	return make(memoryStore)
+}
+
+func (s memoryStore) Get(id string) *Room {
+	return s[id]
+}
+
+func (s memoryStore) Set(id string, room *Room) {
+	s[id] = room
+}
+
+func (s memoryStore) Delete(id string) {
+	delete(s, id)
+}
+
+func (s memoryStore) IDs() []string {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
@@ -0,0 +1,304 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Max length of player and room names.
+	maxNameLength = 64
+	// Max length of chat messages.
+	maxChatLength = 1000
+	// Max length of IDs generated by clients (for requests and actions).
+	maxClientIDLength = 64
+	// Max number of items in the lists sent during the handshake.
+	maxHelloItems = 32
+)
+
+// Reasons for a message failing validation.
+const (
+	// Required field is missing.
+	reasonMissing = "missing"
+	// Field has the wrong type of value.
+	reasonType = "type"
+	// String or list in the field is too long.
+	reasonLength = "length"
+	// Event isn't known to the server.
+	reasonEvent = "event"
+	// Message couldn't be decoded.
+	reasonMalformed = "malformed"
+	// Message is larger than what's allowed.
+	reasonSize = "size"
+)
+
+// jsonKind of the value expected in some field.
+type jsonKind string
+
+const (
+	kindString jsonKind = "string"
+	// Non-negative integers (all numbers in our requests are counts or IDs).
+	kindUint   jsonKind = "uint"
+	kindBool   jsonKind = "boolean"
+	kindObject jsonKind = "object"
+	kindArray  jsonKind = "array"
+)
+
+// fieldSchema for some field in the payload of an event.
+type fieldSchema struct {
+	kind     jsonKind
+	required bool
+	// Max length of strings and arrays (unlimited if this is zero).
+	maxLen int
+	// Fields of objects.
+	fields map[string]fieldSchema
+	// Schema of the items in arrays.
+	items *fieldSchema
+}
+
+// eventSchema declares what an event from the client should look like.
+// Fields which aren't declared are ignored.
+type eventSchema struct {
+	// Whether the event needs a player.
+	player bool
+	// Whether the event needs a room.
+	room bool
+	// Whether the event needs a message (`msg` is ignored otherwise).
+	msg bool
+	// Fields in `data` (if any).
+	data map[string]fieldSchema
+}
+
+// Schema for the cards submitted by the client.
+var cardSchema = fieldSchema{
+	kind:     kindObject,
+	required: true,
+	fields: map[string]fieldSchema{
+		"label": {kind: kindString, required: true, maxLen: 2},
+		"suite": {kind: kindString, required: true, maxLen: 1},
+	},
+}
+
+// Schemas for all events accepted from the clients.
+var eventSchemas = map[string]eventSchema{
+	eventHello: {
+		data: map[string]fieldSchema{
+			"version":      {kind: kindUint, required: true},
+			"capabilities": {kind: kindArray, maxLen: maxHelloItems, items: &fieldSchema{kind: kindString}},
+			"encodings":    {kind: kindArray, maxLen: maxHelloItems, items: &fieldSchema{kind: kindString}},
+		},
+	},
+	eventRoomCreate: {
+		player: true,
+		data: map[string]fieldSchema{
+			"players": {kind: kindUint, required: true},
+		},
+	},
+	eventPlayerJoin:     {player: true, room: true},
+	eventPlayerMsg:      {player: true, room: true, msg: true},
+	eventNewGameRequest: {player: true, room: true},
+	eventResync:         {player: true, room: true},
+	eventPlayerTurn: {
+		player: true,
+		room:   true,
+		data: map[string]fieldSchema{
+			"card":     cardSchema,
+			"actionId": {kind: kindString, maxLen: maxClientIDLength},
+			"turn":     {kind: kindUint},
+		},
+	},
+	eventPlayerReady: {
+		player: true,
+		room:   true,
+		data: map[string]fieldSchema{
+			"ready": {kind: kindBool, required: true},
+		},
+	},
+	eventReplay: {
+		player: true,
+		room:   true,
+		data: map[string]fieldSchema{
+			"since": {kind: kindUint},
+		},
+	},
+}
+
+// Schema of the body for taking a seat through the REST API.
+var apiSeatSchema = map[string]fieldSchema{
+	"player":  {kind: kindString, required: true, maxLen: maxNameLength},
+	"room":    {kind: kindString, maxLen: maxNameLength},
+	"players": {kind: kindUint},
+}
+
+// ValidationDetails for messages which don't match the schema of their event.
+type ValidationDetails struct {
+	// Event of the message.
+	Event string `json:"event"`
+	// Field which is invalid (like `data.card.label`), if any.
+	Field string `json:"field,omitempty"`
+	// Why the message is invalid (`missing`, `type`, `length`, `event`,
+	// `malformed` or `size`).
+	Reason string `json:"reason"`
+}
+
+// invalidMessage returns the error for a message which failed validation.
+func invalidMessage(event, field, reason string) *HandlerError {
+	name := field
+	if name == "" {
+		name = reason
+	}
+
+	return &HandlerError{
+		Code:    errInvalidRequest,
+		key:     msgInvalidField,
+		args:    []interface{}{name},
+		Details: &ValidationDetails{Event: event, Field: field, Reason: reason},
+	}
+}
+
+// messageTooLarge returns the error for a message larger than what's allowed.
+func messageTooLarge(limit int) *HandlerError {
+	return &HandlerError{
+		Code:    errMessageTooLarge,
+		key:     msgMessageTooLarge,
+		args:    []interface{}{limit},
+		Details: &ValidationDetails{Reason: reasonSize},
+	}
+}
+
+// validateMessage against the schema of its event before it's dispatched.
+func validateMessage(msg *GameMessage) *HandlerError {
+	schema, exists := eventSchemas[msg.Event]
+	if !exists {
+		return invalidMessage(msg.Event, "event", reasonEvent)
+	}
+
+	strs := []struct {
+		field, value string
+		required     bool
+		maxLen       int
+	}{
+		{"player", strings.TrimSpace(msg.Player), schema.player, maxNameLength},
+		{"room", strings.TrimSpace(msg.Room), schema.room, maxNameLength},
+		{"msg", strings.TrimSpace(msg.Msg), schema.msg, maxChatLength},
+		{"requestId", msg.RequestID, false, maxClientIDLength},
+	}
+
+	for _, s := range strs {
+		if s.required && s.value == "" {
+			return invalidMessage(msg.Event, s.field, reasonMissing)
+		}
+
+		if utf8.RuneCountInString(s.value) > s.maxLen {
+			return invalidMessage(msg.Event, s.field, reasonLength)
+		}
+	}
+
+	return validateData(msg.Event, "data", schema.data, msg.Data)
+}
+
+// validateData of some event against the given fields. Missing data is
+// treated as an empty object. Invalid fields are reported under the root.
+func validateData(event, root string, fields map[string]fieldSchema, data *json.RawMessage) *HandlerError {
+	raw := json.RawMessage("{}")
+	if data != nil && !bytes.Equal(bytes.TrimSpace(*data), []byte("null")) {
+		raw = *data
+	}
+
+	reason, field := validateValue(fieldSchema{kind: kindObject, fields: fields}, raw, root)
+	if reason != "" {
+		return invalidMessage(event, field, reason)
+	}
+
+	return nil
+}
+
+// validateValue against the schema. Returns the reason and the path of
+// the invalid field (if any).
+func validateValue(schema fieldSchema, raw json.RawMessage, path string) (string, string) {
+	switch schema.kind {
+	case kindString:
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return reasonType, path
+		}
+
+		if schema.maxLen > 0 && utf8.RuneCountInString(s) > schema.maxLen {
+			return reasonLength, path
+		}
+	case kindUint:
+		var n uint64
+		if json.Unmarshal(raw, &n) != nil {
+			return reasonType, path
+		}
+	case kindBool:
+		var b bool
+		if json.Unmarshal(raw, &b) != nil {
+			return reasonType, path
+		}
+	case kindArray:
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return reasonType, path
+		}
+
+		if schema.maxLen > 0 && len(items) > schema.maxLen {
+			return reasonLength, path
+		}
+
+		for _, item := range items {
+			if reason, field := validateValue(*schema.items, item, path+"[]"); reason != "" {
+				return reason, field
+			}
+		}
+	case kindObject:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(raw, &obj) != nil || obj == nil {
+			return reasonType, path
+		}
+
+		for name, f := range schema.fields {
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+
+			v, exists := obj[name]
+			if !exists || bytes.Equal(bytes.TrimSpace(v), []byte("null")) {
+				if f.required {
+					return reasonMissing, fieldPath
+				}
+
+				continue
+			}
+
+			if reason, field := validateValue(f, v, fieldPath); reason != "" {
+				return reason, field
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// rejectFrame which was too large or couldn't be decoded, and let the client
+// know. Returns `false` if the connection should be closed.
+func (hub *Hub) rejectFrame(conn connection, sess *session, err error) bool {
+	// Invalid frames count towards flooding like any other event.
+	e, ok := hub.limitRate(sess, "")
+	if !ok {
+		return false
+	}
+
+	if e == nil && err == errFrameTooLarge {
+		e = messageTooLarge(hub.opts.MaxMessageBytes)
+	} else if e == nil {
+		e = invalidMessage("", "", reasonMalformed)
+	}
+
+	hub.opts.Logger.Printf("Rejecting message from %s: %v\n", sess.addr, err)
+	hub.sendError(conn, "", sess, "", e)
+	return true
+}
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestValidateMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	check := func(msg string) *ValidationDetails {
+		var m GameMessage
+		assert.Nil(json.Unmarshal([]byte(msg), &m))
+		e := validateMessage(&m)
+		if e == nil {
+			return nil
+		}
+
+		assert.Equal(errInvalidRequest, e.Code)
+		return e.Details.(*ValidationDetails)
+	}
+
+	assert.Nil(check(`{"event":"Hello","data":{"version":2,"capabilities":["acks"]}}`))
+	assert.Nil(check(`{"event":"PlayerJoin","player":"bob","room":"attic","data":{}}`))
+	assert.Nil(check(`{"event":"PlayerTurn","player":"bob","room":"attic","data":{"card":{"label":"10","suite":"s"},"turn":3}}`))
+	assert.Nil(check(`{"event":"Resync","player":"bob","room":"attic"}`))
+
+	assert.Equal(&ValidationDetails{Event: "Teleport", Field: "event", Reason: reasonEvent},
+		check(`{"event":"Teleport","player":"bob","room":"attic"}`))
+	assert.Equal(&ValidationDetails{Event: eventPlayerJoin, Field: "player", Reason: reasonMissing},
+		check(`{"event":"PlayerJoin","player":" ","room":"attic"}`))
+	assert.Equal(&ValidationDetails{Event: eventPlayerMsg, Field: "msg", Reason: reasonLength},
+		check(`{"event":"PlayerMsg","player":"bob","room":"attic","msg":"`+strings.Repeat("a", maxChatLength+1)+`"}`))
+	assert.Equal(&ValidationDetails{Event: eventPlayerTurn, Field: "data.card", Reason: reasonMissing},
+		check(`{"event":"PlayerTurn","player":"bob","room":"attic"}`))
+	assert.Equal(&ValidationDetails{Event: eventPlayerTurn, Field: "data.card.suite", Reason: reasonType},
+		check(`{"event":"PlayerTurn","player":"bob","room":"attic","data":{"card":{"label":"A","suite":4}}}`))
+	assert.Equal(&ValidationDetails{Event: eventPlayerTurn, Field: "data", Reason: reasonType},
+		check(`{"event":"PlayerTurn","player":"bob","room":"attic","data":[1]}`))
+	assert.Equal(&ValidationDetails{Event: eventRoomCreate, Field: "data.players", Reason: reasonType},
+		check(`{"event":"RoomCreate","player":"bob","data":{"players":-3}}`))
+	assert.Equal(&ValidationDetails{Event: eventPlayerReady, Field: "data.ready", Reason: reasonMissing},
+		check(`{"event":"PlayerReady","player":"bob","room":"attic","data":{"ready":null}}`))
+	assert.Equal(&ValidationDetails{Event: eventHello, Field: "data.capabilities[]", Reason: reasonType},
+		check(`{"event":"Hello","data":{"version":2,"capabilities":[1]}}`))
+}
+
+func TestInvalidFrames(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub(Options{CleanupInterval: time.Hour, MaxMessageBytes: 128})
+	defer hub.Close()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", "", server.URL)
+	assert.Nil(err)
+	defer ws.Close()
+
+	receive := func() *HandlerError {
+		var msg GameMessage
+		assert.Nil(receiveMessage(ws, &msg))
+		return msg.Error
+	}
+
+	// Connections survive invalid frames and get errors instead.
+	websocket.Message.Send(ws, `{"event":"PlayerMsg","msg":"`+strings.Repeat("a", 200)+`"}`)
+	assert.Equal(errMessageTooLarge, receive().Code)
+	websocket.Message.Send(ws, `{"event":`)
+	assert.Equal(reasonMalformed, receive().Details.(map[string]interface{})["reason"])
+
+	websocket.Message.Send(ws, `{"event":"Hello","data":{"version":2}}`)
+	var msg GameMessage
+	assert.Nil(receiveMessage(ws, &msg))
+	assert.Equal(eventHello, msg.Event)
+}
@@ -1,74 +1,87 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"golang.org/x/net/websocket"
-)
-
-const (
-	// Event for player requesting to join a room and for server
-	// notifying of a player joining some room.
-	eventPlayerJoin = "PlayerJoin"
-	// Event for player creating a new room.
-	eventRoomCreate = "RoomCreate"
-	// Room already exists and is full.
-	eventRoomExists = "RoomExists"
-	// Cannot find room for joining.
-	eventRoomMissing = "RoomMissing"
-	// Player already exists with that name in the room.
-	eventPlayerExists = "PlayerExists"
-	// Event for player submitting a card for their turn and for server
-	// notifying about a player's turn.
-	eventPlayerTurn = "PlayerTurn"
-	// One player has lost.
-	eventGameOver = "GameOver"
-	// Some player has successfully gotten rid of all cards in their hand.
-	eventPlayerWins = "PlayerWin"
-	// Player has sent a message.
-	eventPlayerMsg = "PlayerMsg"
-	// Player has requested for a new game.
-	eventNewGameRequest = "GameRestartRequest"
-	// Server has agreed to restart the game.
-	eventGameRestart = "GameRestart"
-
-	minPlayers                 = 3
-	maxPlayers                 = 6
-	roomDeletionTimeoutMinutes = 5
+	"ace_away/game"
 )
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
-	pathPtr := flag.String("path", "", "Path to serve directory (required).")
-	intPtr := flag.Uint("port", 3000, "Listening port")
+	configPtr := flag.String("config", os.Getenv(envPrefix+"CONFIG"), "Path to config file (JSON)")
+	// Defaults are only shown in the usage. Flags which are set explicitly
+	// are applied over the config file and the environment.
+	registerFlags(flag.CommandLine, defaultConfig())
 	flag.Parse()
 
-	hub := &Hub{
-		rooms:     make(map[string]*Room),
-		connRooms: make(map[*websocket.Conn]string),
-		cmdChan:   make(chan hubCommand),
-		roomChan:  make(chan *Room),
-		connChan:  make(chan string),
-		ackChan:   make(chan bool),
-		ticker:    time.NewTicker(30 * time.Second),
+	cfg, err := loadConfig(*configPtr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.PrintDefaults()
+		os.Exit(1)
 	}
-	go hub.watchEvents()
 
-	if *pathPtr == "" {
-		flag.PrintDefaults()
+	logger, err := cfg.logger()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	fs := http.FileServer(http.Dir(*pathPtr))
-	http.Handle("/", fs)
-	http.Handle("/ws", websocket.Handler(hub.serve))
+	effective, _ := json.Marshal(cfg)
+	logger.Printf("Effective config: %s\n", effective)
+
+	opts := cfg.options(logger)
+	opts.Fallback = http.FileServer(http.Dir(cfg.Path))
+	hub := game.NewHub(opts)
+	handler := withBasePath(cfg.BasePath, hub)
+
+	go watchReloads(hub, cfg, *configPtr, logger)
+
+	if cfg.TLSCert == "" {
+		logger.Printf("Listening on %s\n", cfg.Listen)
+		logger.Fatal(http.ListenAndServe(cfg.Listen, handler))
+	}
+
+	cert, err := loadCertificate(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	go cert.watch(certCheckInterval, logger)
+	if cfg.HTTPRedirect != "" {
+		go func() {
+			logger.Printf("Redirecting HTTP on %s\n", cfg.HTTPRedirect)
+			logger.Fatal(http.ListenAndServe(cfg.HTTPRedirect, httpsRedirect(cfg.Listen)))
+		}()
+	}
+
+	server := &http.Server{
+		Addr:      cfg.Listen,
+		Handler:   handler,
+		TLSConfig: &tls.Config{GetCertificate: cert.get},
+	}
+
+	logger.Printf("Listening on %s (TLS)\n", cfg.Listen)
+	logger.Fatal(server.ListenAndServeTLS("", ""))
+}
+
+// withBasePath serves the handler under the given path prefix (if any).
+func withBasePath(base string, handler http.Handler) http.Handler {
+	base = strings.TrimRight(base, "/")
+	if base == "" {
+		return handler
+	}
 
-	log.Printf("Listening on port %d\n", *intPtr)
-	http.ListenAndServe(fmt.Sprintf(":%d", *intPtr), nil)
+	// Requests for the prefix itself are redirected to the trailing slash.
+	mux := http.NewServeMux()
+	mux.Handle(base+"/", http.StripPrefix(base, handler))
+	return mux
 }
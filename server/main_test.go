@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasePath(t *testing.T) {
+	assert := assert.New(t)
+	paths := make([]string, 0)
+	handler := withBasePath("/ace-away/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+
+	for _, p := range []string{"/ace-away/", "/ace-away/ws", "/ace-away/api/rooms"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		assert.Equal(http.StatusOK, w.Code)
+	}
+
+	assert.Equal([]string{"/", "/ws", "/api/rooms"}, paths)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ace-away", nil))
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("/ace-away/", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ws", nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+}
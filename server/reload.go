@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"syscall"
+
+	"ace_away/game"
+)
+
+// Settings which only take effect after restarting the server.
+var restartSettings = map[string]bool{
+	"listen":          true,
+	"tlsCert":         true,
+	"tlsKey":          true,
+	"httpRedirect":    true,
+	"basePath":        true,
+	"trustProxy":      true,
+	"path":            true,
+	"cleanupInterval": true,
+	"logFile":         true,
+	"logPrefix":       true,
+	"disable":         true,
+	"maxMessageBytes": true,
+}
+
+// settingChange in the config after a reload.
+type settingChange struct {
+	name     string
+	old, new interface{}
+}
+
+// changes from this config to the other one (sorted by name).
+func (c *config) changes(other *config) []settingChange {
+	old, new := c.settings(), other.settings()
+	changes := make([]settingChange, 0)
+	for name, v := range new {
+		if !reflect.DeepEqual(old[name], v) {
+			changes = append(changes, settingChange{name, old[name], v})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].name < changes[j].name
+	})
+
+	return changes
+}
+
+// settings in this config, keyed by their names in the config file.
+func (c *config) settings() map[string]interface{} {
+	values := make(map[string]interface{})
+	data, _ := json.Marshal(c)
+	json.Unmarshal(data, &values)
+	return values
+}
+
+// keepRestartSettings copies the settings which can't be changed at runtime
+// from the other config.
+func (c *config) keepRestartSettings(other *config) {
+	c.Listen, c.Path = other.Listen, other.Path
+	c.BasePath, c.TrustProxy = other.BasePath, other.TrustProxy
+	c.TLSCert, c.TLSKey, c.HTTPRedirect = other.TLSCert, other.TLSKey, other.HTTPRedirect
+	c.CleanupInterval = other.CleanupInterval
+	c.LogFile, c.LogPrefix = other.LogFile, other.LogPrefix
+	c.Disable = other.Disable
+	c.MaxMessageBytes = other.MaxMessageBytes
+}
+
+// watchReloads reloads the config on SIGHUP and applies the settings which
+// are safe to change at runtime.
+//
+// **NOTE:** This must be launched into a separate goroutine.
+func watchReloads(hub *game.Hub, cfg *config, path string, logger *log.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		next, err := loadConfig(path)
+		if err != nil {
+			logger.Printf("Ignoring config reload: %v\n", err)
+			continue
+		}
+
+		changes := cfg.changes(next)
+		for _, c := range changes {
+			if restartSettings[c.name] {
+				logger.Printf("Setting %s needs a restart (keeping %v)\n", c.name, c.old)
+			}
+		}
+
+		next.keepRestartSettings(cfg)
+		if err := hub.Reload(next.options(logger)); err != nil {
+			logger.Printf("Ignoring config reload: %v\n", err)
+			continue
+		}
+
+		for _, c := range changes {
+			if !restartSettings[c.name] {
+				logger.Printf("Setting %s changed from %v to %v\n", c.name, c.old, c.new)
+			}
+		}
+
+		if len(changes) == 0 {
+			logger.Println("Config reloaded without changes.")
+		}
+
+		cfg = next
+	}
+}
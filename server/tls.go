@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interval for checking whether the certificate files have changed.
+const certCheckInterval = time.Minute
+
+// certificate for serving TLS, which is reloaded whenever its files change
+// (e.g., after renewal).
+type certificate struct {
+	certFile, keyFile string
+	lock              sync.RWMutex
+	cert              *tls.Certificate
+	// Modification times of the files when they were loaded.
+	certTime, keyTime time.Time
+}
+
+// loadCertificate from the given PEM files.
+func loadCertificate(certFile, keyFile string) (*certificate, error) {
+	c := &certificate{certFile: certFile, keyFile: keyFile}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// get the current certificate (for `tls.Config.GetCertificate`).
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cert, nil
+}
+
+// reload the certificate if its files have changed. Returns whether it
+// has been reloaded. The current certificate is kept if the files are invalid.
+func (c *certificate) reload() (bool, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false, err
+	}
+
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.lock.RLock()
+	unchanged := certInfo.ModTime().Equal(c.certTime) && keyInfo.ModTime().Equal(c.keyTime)
+	c.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cert = &cert
+	c.certTime, c.keyTime = certInfo.ModTime(), keyInfo.ModTime()
+	return true, nil
+}
+
+// watch the certificate files and reload them when they change.
+//
+// **NOTE:** This must be launched into a separate goroutine.
+func (c *certificate) watch(interval time.Duration, logger *log.Logger) {
+	for range time.Tick(interval) {
+		reloaded, err := c.reload()
+		if err != nil {
+			logger.Printf("Keeping the current certificate: %v\n", err)
+		} else if reloaded {
+			logger.Printf("Reloaded certificate from %s\n", c.certFile)
+		}
+	}
+}
+
+// httpsRedirect redirects plain HTTP requests to the same URL over HTTPS
+// on the given listening address.
+func httpsRedirect(listen string) http.Handler {
+	_, port, _ := net.SplitHostPort(listen)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate generates a self-signed certificate for the given name.
+func writeCertificate(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func TestCertificateReload(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "ace-tls")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	_, err := loadCertificate(certFile, keyFile)
+	assert.NotNil(err)
+
+	writeCertificate(t, certFile, keyFile, "first")
+	c, err := loadCertificate(certFile, keyFile)
+	assert.Nil(err)
+	first, _ := c.get(nil)
+
+	reloaded, err := c.reload()
+	assert.Nil(err)
+	assert.False(reloaded)
+
+	writeCertificate(t, certFile, keyFile, "second")
+	later := time.Now().Add(time.Second)
+	os.Chtimes(certFile, later, later)
+	reloaded, err = c.reload()
+	assert.Nil(err)
+	assert.True(reloaded)
+	second, _ := c.get(nil)
+	assert.NotEqual(first.Certificate, second.Certificate)
+
+	// Broken files don't replace the working certificate.
+	ioutil.WriteFile(certFile, []byte("garbage"), 0600)
+	later = later.Add(time.Second)
+	os.Chtimes(certFile, later, later)
+	_, err = c.reload()
+	assert.NotNil(err)
+	current, _ := c.get(nil)
+	assert.Equal(second, current)
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	assert := assert.New(t)
+
+	w := httptest.NewRecorder()
+	httpsRedirect(":443").ServeHTTP(w, httptest.NewRequest("GET", "http://example.com:80/ace?room=attic", nil))
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("https://example.com/ace?room=attic", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	httpsRedirect(":8443").ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/", nil))
+	assert.Equal("https://example.com:8443/", w.Header().Get("Location"))
+}
@@ -1,9 +1,17 @@
 import {
   ClientMessage, ServerMessage,
-  RoomResponse, GameEvent, DealResponse, Card,
+  RoomResponse, GameEvent, DealResponse, Card, CountdownResponse,
+  HelloRequest,
 } from './model';
 
+/** Version of the protocol spoken by this client. */
+const PROTOCOL_VERSION = 2;
+
+/** Optional protocol features understood by this client. */
+const CAPABILITIES: string[] = [];
+
 import GameEventHub from './';
+import HttpSocket from './http';
 
 interface Callback<F> {
   persist: boolean | undefined;
@@ -18,7 +26,13 @@ interface Callback<F> {
  */
 export default class ConnectionProvider implements GameEventHub {
 
-  private static conn: WebSocket | null = null;
+  private static conn: WebSocket | HttpSocket | null = null;
+
+  /** Callbacks waiting for the handshake of the connection being opened. */
+  private static pending: Array<(ws: WebSocket | HttpSocket) => void> | null = null;
+
+  /** Whether we've given up on websockets (e.g., blocked by some proxy). */
+  private static useHttp: boolean = false;
 
   private static callbacks: { [key in GameEvent]?: Array<Callback<(resp: any) => void>> } = {};
 
@@ -26,6 +40,9 @@ export default class ConnectionProvider implements GameEventHub {
 
   private static socketErrorCallbacks: Array<Callback<() => void>> = [];
 
+  /** Number of requests sent so far (used for generating request IDs). */
+  private static requestCount: number = 0;
+
   private static disconnectCallbacks: Array<Callback<() => void>> = [];
 
   /* Interface methods */
@@ -38,6 +55,7 @@ export default class ConnectionProvider implements GameEventHub {
       data: {
         players: numPlayers,
       },
+      locale: navigator.language,
     });
   }
 
@@ -47,16 +65,21 @@ export default class ConnectionProvider implements GameEventHub {
       room: roomName,
       event: GameEvent.playerJoin,
       data: {},
+      locale: navigator.language,
     });
   }
 
-  public showCard(playerId: string, roomName: string, card: Card) {
+  public showCard(playerId: string, roomName: string, card: Card, turn?: number) {
+    // Retried submissions with the same action ID are applied only once by the server.
+    const actionId = `${playerId}-${turn}-${card.label}${card.suite}`;
     this.sendMessage({
       player: playerId,
       room: roomName,
       event: GameEvent.playerTurn,
       data: {
         card,
+        actionId,
+        turn,
       },
     });
   }
@@ -70,6 +93,17 @@ export default class ConnectionProvider implements GameEventHub {
     });
   }
 
+  public setReady(playerId: string, roomName: string, ready: boolean) {
+    this.sendMessage({
+      player: playerId,
+      room: roomName,
+      event: GameEvent.playerReady,
+      data: {
+        ready,
+      },
+    });
+  }
+
   public sendMsg(playerId: string, roomName: string, msg: string) {
     this.sendMessage({
       player: playerId,
@@ -100,14 +134,18 @@ export default class ConnectionProvider implements GameEventHub {
     this.onEvent(GameEvent.gameOver, callback, persist);
   }
 
-  public onGameRequest(callback: (resp: ServerMessage<{}>) => void, persist?: boolean) {
-    this.onEvent(GameEvent.restartRequest, callback, persist);
-  }
-
   public onGameRestart(callback: (resp: ServerMessage<{}>) => void, persist?: boolean) {
     this.onEvent(GameEvent.gameRestart, callback, persist);
   }
 
+  public onPlayerReady(callback: (resp: ServerMessage<RoomResponse>) => void, persist?: boolean) {
+    this.onEvent(GameEvent.playerReady, callback, persist);
+  }
+
+  public onGameCountdown(callback: (resp: ServerMessage<CountdownResponse>) => void, persist?: boolean) {
+    this.onEvent(GameEvent.gameCountdown, callback, persist);
+  }
+
   public onError(callback: (msg: string, event: GameEvent) => void, persist?: boolean) {
     ConnectionProvider.errorCallbacks.push({
       callback,
@@ -179,6 +217,9 @@ export default class ConnectionProvider implements GameEventHub {
    * @param msg JSON object.
    */
   private sendMessage<T>(msg: ClientMessage<T>) {
+    // Server echoes this ID in its replies (and errors) for this request.
+    ConnectionProvider.requestCount += 1;
+    msg.requestId = `${ConnectionProvider.requestCount}`;
     this.withConnection((ws) => {
       ws.send(JSON.stringify(msg));
     });
@@ -186,28 +227,58 @@ export default class ConnectionProvider implements GameEventHub {
 
   /**
    * Provides the `WebSocket` object to the caller through a callback.
-   * Instantiates or reuses the websocket as required.
+   * Instantiates or reuses the websocket as required. Falls back to
+   * plain HTTP if the websocket can't be opened. Callers are called
+   * only after the server has replied to our handshake.
    */
-  private withConnection(callback: (ws: WebSocket) => void) {
+  private withConnection(callback: (ws: WebSocket | HttpSocket) => void) {
     if (ConnectionProvider.conn) {
       return callback(ConnectionProvider.conn);
     }
 
+    if (ConnectionProvider.pending) {
+      ConnectionProvider.pending.push(callback);
+      return;
+    }
+
+    ConnectionProvider.pending = [callback];
+
     let protocol = 'wss';
     if (window.location.protocol.indexOf('https') < 0) {
       protocol = 'ws';
     }
 
-    let url = `${protocol}://${window.location.host}${window.location.pathname}`;
-    if (!url.endsWith('/')) {
-      url += '/';
+    let path = window.location.pathname;
+    if (!path.endsWith('/')) {
+      path += '/';
     }
-    url += 'ws';
 
-    const socket = new WebSocket(url);
+    let opened = false;
+    const socket = ConnectionProvider.useHttp
+      ? new HttpSocket(`${window.location.protocol}//${window.location.host}${path}http/`)
+      : new WebSocket(`${protocol}://${window.location.host}${path}ws`);
     socket.onopen = () => {
-      ConnectionProvider.conn = socket;
-      callback(socket);
+      opened = true;
+      // Anything sent before the server's reply may be rejected as coming from an old client.
+      this.onEvent(GameEvent.hello, () => {
+        const pending = ConnectionProvider.pending || [];
+        ConnectionProvider.conn = socket;
+        ConnectionProvider.pending = null;
+        pending.forEach((c) => c(socket));
+      });
+
+      // Server expects a handshake before anything else.
+      const hello: ClientMessage<HelloRequest> = {
+        player: '',
+        room: '',
+        event: GameEvent.hello,
+        data: {
+          version: PROTOCOL_VERSION,
+          capabilities: CAPABILITIES,
+        },
+        locale: navigator.language,
+      };
+      socket.send(JSON.stringify(hello));
     };
 
     socket.onmessage = (e) => {
@@ -215,11 +286,23 @@ export default class ConnectionProvider implements GameEventHub {
     };
 
     socket.onerror = () => {
+      if (!opened && !ConnectionProvider.useHttp) {
+        // Websocket couldn't even be opened. Try plain HTTP instead.
+        ConnectionProvider.useHttp = true;
+        socket.onclose = null;
+        const pending = ConnectionProvider.pending || [];
+        ConnectionProvider.pending = null;
+        pending.forEach((c) => this.withConnection(c));
+        return;
+      }
+
       ConnectionProvider.socketErrorCallbacks.forEach((c) => c.callback());
       ConnectionProvider.socketErrorCallbacks = ConnectionProvider.socketErrorCallbacks.filter((c) => c.persist);
     };
 
     socket.onclose = () => {
+      // Nothing's getting sent if we never got past the handshake.
+      ConnectionProvider.pending = null;
       ConnectionProvider.disconnectCallbacks.forEach((c) => c.callback());
       ConnectionProvider.disconnectCallbacks = ConnectionProvider.disconnectCallbacks.filter((c) => c.persist);
     };
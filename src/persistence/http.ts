@@ -0,0 +1,60 @@
+/**
+ * Fallback for networks which block websockets. Messages from the server are
+ * received as server-sent events and our messages are sent via `POST`.
+ *
+ * This mimics the parts of `WebSocket` used by the connection provider.
+ */
+export default class HttpSocket {
+
+  public onopen: (() => void) | null = null;
+
+  public onmessage: ((e: MessageEvent) => void) | null = null;
+
+  public onerror: (() => void) | null = null;
+
+  public onclose: (() => void) | null = null;
+
+  private session: string | null = null;
+
+  private events: EventSource | null = null;
+
+  /** Messages sent so far (each one is posted only after the previous one is done). */
+  private outbox: Promise<void> = Promise.resolve();
+
+  /**
+   * @param baseUrl URL where the server's HTTP transport is mounted (ending with `/`).
+   */
+  constructor(private baseUrl: string) {
+    fetch(`${baseUrl}connect`, { method: 'POST' })
+      .then((resp) => resp.json())
+      .then((resp: { session: string }) => {
+        this.session = resp.session;
+        this.events = new EventSource(`${baseUrl}events?session=${this.session}`);
+        this.events.onopen = () => this.onopen && this.onopen();
+        this.events.onmessage = (e) => this.onmessage && this.onmessage(e);
+        this.events.onerror = () => {
+          // Browsers keep retrying unless the stream has been closed for good.
+          if (this.events!.readyState === EventSource.CLOSED) {
+            this.onclose && this.onclose();
+          }
+        };
+      })
+      .catch(() => this.onerror && this.onerror());
+  }
+
+  public send(data: string) {
+    // Server handles the messages in the order they arrive, so we shouldn't
+    // have more than one request in flight.
+    this.outbox = this.outbox
+      .then(() => fetch(`${this.baseUrl}send?session=${this.session}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: data,
+      }))
+      .then(() => undefined, () => {
+        if (this.onerror) {
+          this.onerror();
+        }
+      });
+  }
+}
@@ -1,5 +1,5 @@
 import {
-    ServerMessage, Card, RoomResponse, GameEvent, DealResponse,
+    ServerMessage, Card, RoomResponse, GameEvent, DealResponse, CountdownResponse,
 } from './model';
 
 /**
@@ -24,11 +24,19 @@ export default interface GameEventHub {
      * Submits the player's card for that turn.
      *
      * @param req Turn message.
+     * @param turn Turn for which the card is being submitted.
      */
-    showCard(playerId: string, roomName: string, card: Card): void;
+    showCard(playerId: string, roomName: string, card: Card, turn?: number): void;
 
     requestNewGmae(playerId: string, roomName: string): void;
 
+    /**
+     * Toggles the player's readiness for the next game.
+     *
+     * @param ready Whether the player is ready.
+     */
+    setReady(playerId: string, roomName: string, ready: boolean): void;
+
     /**
      * Submits the player's message to other players.
      *
@@ -84,10 +92,30 @@ export default interface GameEventHub {
      */
     onGameOver(callback: (resp: ServerMessage<{}>) => void, persist?: boolean): void;
 
-    onGameRequest(callback: (resp: ServerMessage<{}>) => void, persist?: boolean): void;
-
+    /**
+     * Adds a listener for some player ending the game and taking the room back to the lobby.
+     *
+     * @param callback Callback function
+     * @param persist Whether to persist that callback or destroy it after the first call.
+     */
     onGameRestart(callback: (resp: ServerMessage<{}>) => void, persist?: boolean): void;
 
+    /**
+     * Adds a listener for player readiness event.
+     *
+     * @param callback Callback function
+     * @param persist Whether to persist that callback or destroy it after the first call.
+     */
+    onPlayerReady(callback: (resp: ServerMessage<RoomResponse>) => void, persist?: boolean): void;
+
+    /**
+     * Adds a listener for the countdown before dealing.
+     *
+     * @param callback Callback function
+     * @param persist Whether to persist that callback or destroy it after the first call.
+     */
+    onGameCountdown(callback: (resp: ServerMessage<CountdownResponse>) => void, persist?: boolean): void;
+
     onSocketError(callback: () => void, persist?: boolean): void;
 
     onSocketClose(callback: () => void, persist?: boolean): void;
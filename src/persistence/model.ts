@@ -6,6 +6,8 @@ interface ClientMessage<T> {
   event: GameEvent;
   data: T;
   msg?: string;
+  locale?: string;
+  requestId?: string;
 }
 
 interface ServerMessage<T> {
@@ -14,14 +16,64 @@ interface ServerMessage<T> {
   msg: string;
   event: GameEvent;
   response: T;
+  phase?: RoomPhase;
+  error?: HandlerError;
+  seq?: number;
+  timestamp?: number;
+  requestId?: string;
+}
+
+interface HandlerError {
+  event: GameEvent;
+  code: string;
+  msg: string;
+  details?: any;
+}
+
+enum RoomPhase {
+  lobby = 'Lobby',
+  dealing = 'Dealing',
+  inTrick = 'InTrick',
+  trickResolved = 'TrickResolved',
+  gameOver = 'GameOver',
+}
+
+interface HelloRequest {
+  version: number;
+  capabilities: string[];
+  encodings?: string[];
+}
+
+interface HelloResponse {
+  version: number;
+  minVersion: number;
+  features: string[];
+  enabled: string[];
+  encoding: string;
+}
+
+interface ReplayRequest {
+  since: number;
+}
+
+interface ReplayResponse {
+  since: number;
+  count: number;
+  complete: boolean;
 }
 
 interface RoomCreationRequest {
   players: number;
 }
 
+interface ReadyRequest {
+  ready: boolean;
+}
+
 interface TurnRequest {
   card: Card;
+  actionId?: string;
+  turn?: number;
 }
 
 interface RoomResponse {
@@ -29,6 +81,21 @@ interface RoomResponse {
   max: number;
   escaped: string[];
   turnIdx: number;
+  ready: string[];
+}
+
+interface TrickResponse {
+  table: PlayerCard[];
+  leader: string;
+  suite: Suite;
+  breaker: string;
+  pickedUpBy: string;
+  pickedUp: number;
+  dealer: string;
+}
+
+interface CountdownResponse {
+  seconds: number;
 }
 
 interface DealResponse {
@@ -37,6 +104,11 @@ interface DealResponse {
   isDealer: boolean;
   ourTurn: boolean;
   turnPlayer: string;
+  opponentHands: { [id: string]: number };
+  discarded: Card[];
+  aceCards: Card[];
+  legalCards: Card[];
+  turn: number;
 }
 
 enum GameEvent {
@@ -51,6 +123,40 @@ enum GameEvent {
   gameOver = 'GameOver',
   gameRestart = 'GameRestart',
   restartRequest = 'GameRestartRequest',
+  playerReady = 'PlayerReady',
+  gameCountdown = 'GameCountdown',
+  invalidPhase = 'InvalidPhase',
+  trickResolved = 'TrickResolved',
+  hello = 'Hello',
+  upgradeRequired = 'UpgradeRequired',
+  replay = 'Replay',
+  ack = 'Ack',
+  playerTurnDelta = 'PlayerTurnDelta',
+  resync = 'Resync',
+  rateLimited = 'RateLimited',
+  serverFull = 'ServerFull',
+}
+
+interface DeltaResponse {
+  base: number;
+  table: PlayerCard[];
+  tableReset: boolean;
+  handAdded: Card[];
+  handRemoved: Card[];
+  isDealer: boolean;
+  ourTurn: boolean;
+  turnPlayer: string;
+  opponentHands: { [id: string]: number };
+  discarded: Card[];
+  discardedReset: boolean;
+  aceCards: Card[];
+  aceCardsReset: boolean;
+  legalCards: Card[];
+  turn: number;
+}
+
+interface AckResponse {
+  event: GameEvent;
 }
 
 interface PlayerCard {
@@ -122,4 +228,7 @@ export {
   Card, Label, Suite, labelRanks, suiteIndices, suitePrettyMap,
   DealResponse, RoomResponse, GameEvent, RoomCreationRequest,
   ClientMessage, ServerMessage, PlayerCard, TurnRequest,
+  ReadyRequest, CountdownResponse, RoomPhase, TrickResponse, HandlerError,
+  HelloRequest, HelloResponse, ReplayRequest, ReplayResponse, AckResponse,
+  DeltaResponse,
 };
@@ -1,5 +1,5 @@
 import {
-  Card, ServerMessage, RoomResponse, GameEvent, DealResponse,
+  Card, ServerMessage, RoomResponse, GameEvent, DealResponse, CountdownResponse,
 } from './persistence/model';
 import { Callback } from './persistence/connection';
 
@@ -23,7 +23,7 @@ export default class TutorialProvider implements GameEventHub {
     console.warn(`Ignoring player message during tutorial.`);
   }
 
-  public showCard(playerId: string, roomName: string, card: Card) {
+  public showCard(playerId: string, roomName: string, card: Card, turn?: number) {
     //
   }
 
@@ -31,6 +31,10 @@ export default class TutorialProvider implements GameEventHub {
     //
   }
 
+  public setReady(playerId: string, roomName: string, ready: boolean) {
+    //
+  }
+
   public onPlayerJoin(callback: (resp: ServerMessage<RoomResponse>) => void, persist?: boolean) {
     this.onEvent(GameEvent.playerJoin, callback, persist);
   }
@@ -51,11 +55,15 @@ export default class TutorialProvider implements GameEventHub {
     //
   }
 
-  public onGameRequest(callback: (resp: ServerMessage<{}>) => void, persist?: boolean) {
+  public onGameRestart(callback: (resp: ServerMessage<{}>) => void, persist?: boolean) {
+    //
+  }
+
+  public onPlayerReady(callback: (resp: ServerMessage<RoomResponse>) => void, persist?: boolean) {
     //
   }
 
-  public onGameRestart(callback: (resp: ServerMessage<{}>) => void, persist?: boolean) {
+  public onGameCountdown(callback: (resp: ServerMessage<CountdownResponse>) => void, persist?: boolean) {
     //
   }
 